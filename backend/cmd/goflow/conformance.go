@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goflow/visualizer/internal/conformance"
+	"github.com/goflow/visualizer/internal/sandbox"
+)
+
+func runConformance(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goflow conformance [flags] <dir>")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Runs every .go file in dir through the interpreter and through the local")
+		fmt.Fprintln(fs.Output(), "Go toolchain, and reports output mismatches, unsupported constructs and panics.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	verbose := fs.Bool("v", false, "list passing programs and full outputs of failing ones")
+	unisolated := fs.Bool("allow-unisolated", false, "run the programs as plain subprocesses where namespaces are unavailable")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	limits := sandbox.DefaultConfig()
+	limits.AllowUnisolated = *unisolated
+	results, err := conformance.CheckDir(fs.Arg(0), limits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+		return 1
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		printConformance(results, *verbose)
+	}
+
+	for _, r := range results {
+		if r.Status != conformance.StatusPass {
+			return 1
+		}
+	}
+	return 0
+}
+
+func printConformance(results []conformance.Result, verbose bool) {
+	for _, r := range results {
+		if r.Status == conformance.StatusPass && !verbose {
+			continue
+		}
+		fmt.Printf("%-11s %s\n", strings.ToUpper(string(r.Status)), r.File)
+		for _, u := range r.Unsupported {
+			fmt.Printf("    unsupported: %s\n", u)
+		}
+		if r.Detail != "" {
+			for _, line := range strings.Split(strings.TrimRight(r.Detail, "\n"), "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+		if verbose && r.Status != conformance.StatusPass {
+			fmt.Printf("    --- go output\n%s", indent(r.Expected))
+			fmt.Printf("    --- interpreter output\n%s", indent(r.Actual))
+		}
+	}
+
+	counts := conformance.Summary(results)
+	fmt.Printf("\n%d programs: %d pass, %d mismatch, %d unsupported, %d panic, %d error\n",
+		len(results),
+		counts[conformance.StatusPass],
+		counts[conformance.StatusMismatch],
+		counts[conformance.StatusUnsupported],
+		counts[conformance.StatusPanic],
+		counts[conformance.StatusError])
+}
+
+func indent(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return "    " + strings.Join(lines, "\n    ") + "\n"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/goflow/visualizer/internal/sandbox"
+)
+
+// command is a goflow subcommand; run returns the process exit code
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"trace", "trace a program and print JSON, a step table or an interactive stepper", runTrace},
+	{"conformance", "compare the interpreter with real Go on a directory of programs", runConformance},
+	{"schema", "generate the trace JSON Schema and the OpenAPI document", runSchema},
+}
+
+func main() {
+	// Must run first: when started as the sandbox helper this never returns
+	sandbox.Init()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "goflow: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: goflow <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goflow/visualizer/internal/openapi"
+)
+
+// generated are the files the schema command writes, relative to its dir
+var generated = []struct {
+	name     string
+	generate func() ([]byte, error)
+}{
+	{"trace-schema.json", openapi.TraceSchema},
+	{"openapi.json", openapi.Document},
+}
+
+func runSchema(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goflow schema [flags] <dir>")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Writes trace-schema.json and openapi.json, generated from the Go types, to dir.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	check := fs.Bool("check", false, "write nothing; fail if the files in dir are out of date")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	stale := false
+	for _, file := range generated {
+		path := filepath.Join(fs.Arg(0), file.name)
+		data, err := file.generate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+			return 1
+		}
+
+		if *check {
+			current, err := os.ReadFile(path)
+			if err != nil || !bytes.Equal(current, data) {
+				fmt.Fprintf(os.Stderr, "goflow: %s is out of date; run goflow schema\n", path)
+				stale = true
+			}
+			continue
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+			return 1
+		}
+	}
+	if stale {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goflow/visualizer/internal/api"
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// ANSI sequences used by the stepper
+const (
+	clearScreen  = "\033[H\033[2J"
+	highlightOn  = "\033[7m"
+	highlightOff = "\033[0m"
+)
+
+// stepper is a line-driven terminal UI that walks through a trace
+type stepper struct {
+	out     io.Writer
+	source  []string
+	steps   []tracer.Step
+	current int
+}
+
+// runStepper reads commands from the terminal, so it works even when the
+// program itself came from stdin
+func runStepper(resp *api.TraceResponse) error {
+	if len(resp.Trace) == 0 {
+		return fmt.Errorf("the trace has no steps")
+	}
+
+	in := io.Reader(os.Stdin)
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		in = tty
+	}
+
+	s := &stepper{
+		out:    os.Stdout,
+		source: strings.Split(resp.SourceCode, "\n"),
+		steps:  resp.Trace,
+	}
+	s.run(bufio.NewScanner(in))
+	return nil
+}
+
+func (s *stepper) run(input *bufio.Scanner) {
+	for {
+		s.render()
+		if !input.Scan() {
+			return
+		}
+
+		cmd := strings.Fields(input.Text())
+		if len(cmd) == 0 {
+			cmd = []string{"n"}
+		}
+		switch cmd[0] {
+		case "n", "next":
+			s.move(s.current + 1)
+		case "p", "prev":
+			s.move(s.current - 1)
+		case "f", "first":
+			s.move(0)
+		case "l", "last":
+			s.move(len(s.steps) - 1)
+		case "g", "goto":
+			if len(cmd) > 1 {
+				if n, err := strconv.Atoi(cmd[1]); err == nil {
+					s.move(n)
+				}
+			}
+		case "q", "quit":
+			return
+		}
+	}
+}
+
+func (s *stepper) move(to int) {
+	if to >= 0 && to < len(s.steps) {
+		s.current = to
+	}
+}
+
+func (s *stepper) render() {
+	step := s.steps[s.current]
+	fmt.Fprint(s.out, clearScreen)
+	fmt.Fprintf(s.out, "Step %d/%d  line %d  %s\n\n", s.current, len(s.steps)-1, step.Line, step.Statement)
+
+	for i, line := range s.source {
+		text := fmt.Sprintf("%4d  %s", i+1, strings.ReplaceAll(line, "\t", "    "))
+		if i+1 == step.Line {
+			fmt.Fprintf(s.out, "%s> %s%s\n", highlightOn, text, highlightOff)
+		} else {
+			fmt.Fprintf(s.out, "  %s\n", text)
+		}
+	}
+
+	if len(step.CallStack) > 0 {
+		fmt.Fprintf(s.out, "\nCall stack: %s\n", strings.Join(step.CallStack, " > "))
+	}
+	if step.LoopIteration != nil {
+		fmt.Fprintf(s.out, "Loop %s, iteration %d\n", step.LoopIteration.LoopID, step.LoopIteration.Iteration)
+	}
+
+	fmt.Fprintln(s.out, "\nVariables:")
+	if len(step.Variables) == 0 {
+		fmt.Fprintln(s.out, "  (none)")
+	}
+	for _, v := range step.Variables {
+		fmt.Fprintf(s.out, "  %-12s %-10s = %s\n", v.Name, v.Type, formatValue(v.Value))
+	}
+
+	fmt.Fprintln(s.out, "\nOutput:")
+	var output strings.Builder
+	for _, prev := range s.steps[:s.current+1] {
+		output.WriteString(prev.Output)
+	}
+	fmt.Fprint(s.out, indent(output.String()))
+
+	fmt.Fprint(s.out, "\n[Enter/n] next  [p] prev  [f] first  [l] last  [g N] goto  [q] quit > ")
+}
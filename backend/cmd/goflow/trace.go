@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goflow/visualizer/internal/api"
+	"github.com/goflow/visualizer/internal/executor"
+	"github.com/goflow/visualizer/internal/export"
+	"github.com/goflow/visualizer/internal/sandbox"
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// Output formats for the trace command
+const (
+	formatJSON    = "json"
+	formatTable   = "table"
+	formatStep    = "step"
+	formatMermaid = "mermaid"
+	formatDOT     = "dot"
+	formatHTML    = "html"
+	formatChrome  = "chrome"
+)
+
+func runTrace(args []string) int {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goflow trace [flags] [file.go | -]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Traces a program read from file, or from stdin when the file is - or missing.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", formatJSON, "output format: json (same as /api/v1/trace), table, step (interactive stepper), mermaid, dot, html (offline player) or chrome (Perfetto)")
+	mode := fs.String("mode", "interpreter", "execution mode: interpreter or native")
+	unisolated := fs.Bool("allow-unisolated", false, "run native programs as plain subprocesses where namespaces are unavailable")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	code, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+		return 1
+	}
+
+	var execute api.Executor
+	switch *mode {
+	case "interpreter":
+		execute = executor.ExecuteSimple
+	case "native":
+		execute = func(code string) ([]tracer.Step, string, error) {
+			limits := sandbox.DefaultConfig()
+			limits.AllowUnisolated = *unisolated
+			return executor.ExecuteNative(code, limits)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "goflow: unknown execution mode %q\n", *mode)
+		return 2
+	}
+
+	response, err := api.Trace(code, execute)
+	if err != nil {
+		// The player and the other formats only need the message
+		response = &api.TraceResponse{Success: false, Error: err.Error(), SourceCode: code}
+	}
+
+	switch *format {
+	case formatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		// Failures use the error object the server sends under /api/v1
+		if err != nil {
+			enc.Encode(api.ErrorResponse{Success: false, Error: err.(*api.Error)})
+		} else {
+			enc.Encode(response)
+		}
+	case formatTable:
+		if response.Success {
+			printTable(os.Stdout, response.Trace)
+		}
+	case formatMermaid:
+		if response.Success {
+			fmt.Print(export.Mermaid(response.AST, response.Trace))
+		}
+	case formatDOT:
+		if response.Success {
+			fmt.Print(export.DOT(response.AST, response.Trace))
+		}
+	case formatChrome:
+		if response.Success {
+			data, err := export.ChromeTrace(response.Trace)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+				return 1
+			}
+			os.Stdout.Write(data)
+		}
+	case formatHTML:
+		// The player shows parse and execution errors itself
+		if err := export.HTML(os.Stdout, response, title(fs.Arg(0))); err != nil {
+			fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+			return 1
+		}
+	case formatStep:
+		if response.Success {
+			if err := runStepper(response); err != nil {
+				fmt.Fprintf(os.Stderr, "goflow: %v\n", err)
+				return 1
+			}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "goflow: unknown format %q\n", *format)
+		return 2
+	}
+
+	if !response.Success {
+		if *format != formatJSON {
+			fmt.Fprintf(os.Stderr, "goflow: %s\n", response.Error)
+		}
+		return 1
+	}
+	return 0
+}
+
+// title names the exported page after the traced file
+func title(path string) string {
+	if path == "" || path == "-" {
+		return ""
+	}
+	return "GoFlow trace: " + filepath.Base(path)
+}
+
+// readSource reads the program from path, or from stdin for "" and "-"
+func readSource(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "" || path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("no code to trace")
+	}
+	return string(data), nil
+}
+
+// printTable writes one row per step with the variables that changed in it
+func printTable(w io.Writer, steps []tracer.Step) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tLINE\tSTATEMENT\tCHANGED\tOUTPUT")
+
+	var prev []tracer.Variable
+	for _, step := range steps {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\n",
+			step.StepIndex,
+			step.Line,
+			step.Statement,
+			strings.Join(changedVariables(prev, step.Variables), ", "),
+			strings.ReplaceAll(step.Output, "\n", `\n`))
+		prev = step.Variables
+	}
+	tw.Flush()
+}
+
+// changedVariables lists "name=value" for variables that are new or whose
+// value differs from the previous step, in name order
+func changedVariables(prev, cur []tracer.Variable) []string {
+	before := make(map[string]string, len(prev))
+	for _, v := range prev {
+		before[v.Scope+"."+v.Name] = formatValue(v.Value)
+	}
+
+	var changed []string
+	for _, v := range cur {
+		value := formatValue(v.Value)
+		if old, ok := before[v.Scope+"."+v.Name]; ok && old == value {
+			continue
+		}
+		changed = append(changed, v.Name+"="+value)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/goflow/visualizer/internal/executor"
+	"github.com/goflow/visualizer/internal/sandbox"
+)
+
+// Execution modes a request can ask for
+const (
+	modeInterpreter = "interpreter"
+	modeNative      = "native"
+)
+
+// envPrefix is prepended to a flag's name, upper-cased with dashes turned
+// into underscores, to get the environment variable that can also set it:
+// -cors-origins is GOFLOW_CORS_ORIGINS
+const envPrefix = "GOFLOW_"
+
+// writeSlack is the time left, past the longest native run, to write the
+// response when -write-timeout is derived
+const writeSlack = 10 * time.Second
+
+// serverConfig holds the settings chosen on the command line or environment
+type serverConfig struct {
+	Addr string
+	// CORSOrigins lists origins allowed to call the API; "*" allows any
+	CORSOrigins  []string
+	MaxCodeBytes int
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// after SIGTERM
+	ShutdownTimeout time.Duration
+	TLSCert         string
+	TLSKey          string
+	// RateLimit is the sustained requests per second allowed per client on
+	// the endpoints that run programs, RateBurst the bucket size; a zero
+	// rate disables the limit
+	RateLimit float64
+	RateBurst int
+	// TrustedProxyHeader names the header a reverse proxy puts the client
+	// address in, such as X-Forwarded-For; empty uses the connection's address
+	TrustedProxyHeader string
+	// MaxConcurrent caps programs executing at once; QueueTimeout is how
+	// long a request waits for a slot before it is turned away
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+	// SlowTrace is the run time from which traces are logged with their code
+	SlowTrace time.Duration
+
+	// Modes lists the execution modes requests may choose from
+	Modes       []string
+	DefaultMode string
+	Sandbox     sandbox.Config
+	Share       shareConfig
+	// CacheBytes bounds the trace cache; 0 disables it
+	CacheBytes int64
+}
+
+// shareConfig controls where shared programs are stored and for how long
+type shareConfig struct {
+	// Store is "", "fs" or "kv"; empty disables sharing
+	Store         string
+	Path          string
+	MaxCodeBytes  int
+	MaxTraceBytes int
+	// TTL is the default lifetime of a link and MaxTTL the longest a request
+	// may ask for; zero means links don't expire
+	TTL    time.Duration
+	MaxTTL time.Duration
+}
+
+func loadConfig(args []string) (serverConfig, error) {
+	cfg := serverConfig{Sandbox: sandbox.DefaultConfig()}
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "address to listen on")
+	origins := fs.String("cors-origins", "*", "comma-separated origins allowed to call the API, or * for any")
+	fs.IntVar(&cfg.MaxCodeBytes, "max-code-bytes", 64<<10, "largest program accepted by the trace endpoints")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", 10*time.Second, "time allowed to read a request")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", 0, "time allowed to handle a request and write the response; must exceed -queue-timeout plus the native build and -sandbox-timeout (0 = derive it from them)")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", 60*time.Second, "how long keep-alive connections stay open between requests")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for in-flight requests on SIGTERM")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "TLS certificate file; serves HTTPS together with -tls-key")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "TLS private key file")
+	modes := fs.String("modes", modeInterpreter, "comma-separated execution modes requests may use (interpreter, native)")
+	fs.StringVar(&cfg.DefaultMode, "default-mode", modeInterpreter, "execution mode used when a request doesn't pick one")
+	fs.DurationVar(&cfg.Sandbox.Timeout, "sandbox-timeout", cfg.Sandbox.Timeout, "wall-clock limit for native runs")
+	fs.DurationVar(&cfg.Sandbox.CPUTime, "sandbox-cpu", cfg.Sandbox.CPUTime, "CPU time limit for native runs")
+	memoryMB := fs.Uint64("sandbox-memory-mb", cfg.Sandbox.MemoryBytes>>20, "memory limit for native runs, in MiB")
+	fs.Int64Var(&cfg.Sandbox.OutputBytes, "sandbox-output-bytes", cfg.Sandbox.OutputBytes, "stdout limit for native runs")
+	fs.BoolVar(&cfg.Sandbox.Isolate, "sandbox-isolate", cfg.Sandbox.Isolate, "run native programs in their own namespaces with a read-only filesystem")
+	fs.BoolVar(&cfg.Sandbox.AllowUnisolated, "sandbox-allow-unisolated", false, "run native programs as plain subprocesses, with network access and a writable filesystem, where namespaces are unavailable")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", 5, "requests per second each client may make to endpoints that run programs (0 disables)")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", 20, "requests a client may make in a burst before -rate-limit applies")
+	fs.StringVar(&cfg.TrustedProxyHeader, "trusted-proxy-header", "", "header carrying the client address when behind a reverse proxy, e.g. X-Forwarded-For")
+	fs.IntVar(&cfg.MaxConcurrent, "max-concurrent", 2*runtime.NumCPU(), "programs that may execute at once")
+	fs.DurationVar(&cfg.QueueTimeout, "queue-timeout", 2*time.Second, "how long a request waits for an execution slot before getting 429")
+	fs.DurationVar(&cfg.SlowTrace, "slow-trace", 2*time.Second, "log the code of traces that take at least this long (0 disables)")
+	fs.Int64Var(&cfg.CacheBytes, "cache-bytes", 64<<20, "memory budget for cached interpreter traces (0 disables the cache)")
+	fs.StringVar(&cfg.Share.Store, "share-store", "", "storage for shared programs: fs (one file per share), kv (single log file), or empty to disable sharing")
+	fs.StringVar(&cfg.Share.Path, "share-path", "goflow-shares", "directory (fs) or file (kv) that holds shared programs")
+	fs.IntVar(&cfg.Share.MaxCodeBytes, "share-max-code-bytes", 64<<10, "largest program that can be shared")
+	fs.IntVar(&cfg.Share.MaxTraceBytes, "share-max-trace-bytes", 1<<20, "largest trace kept with a share; bigger traces are re-executed on load")
+	fs.DurationVar(&cfg.Share.TTL, "share-ttl", 0, "default lifetime of shared links (0 = never expire)")
+	fs.DurationVar(&cfg.Share.MaxTTL, "share-max-ttl", 0, "longest lifetime a share request may ask for (0 = unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if err := applyEnv(fs); err != nil {
+		return cfg, err
+	}
+
+	cfg.Sandbox.MemoryBytes = *memoryMB << 20
+	for _, mode := range strings.Split(*modes, ",") {
+		mode = strings.TrimSpace(mode)
+		if mode != modeInterpreter && mode != modeNative {
+			return cfg, fmt.Errorf("unknown execution mode %q", mode)
+		}
+		cfg.Modes = append(cfg.Modes, mode)
+	}
+	if !cfg.modeAllowed(cfg.DefaultMode) {
+		return cfg, fmt.Errorf("default mode %q is not in -modes", cfg.DefaultMode)
+	}
+	if s := cfg.Share.Store; s != "" && s != "fs" && s != "kv" {
+		return cfg, fmt.Errorf("unknown share store %q", s)
+	}
+	if cfg.RateLimit < 0 || cfg.RateLimit > 0 && cfg.RateBurst < 1 {
+		return cfg, fmt.Errorf("-rate-limit must not be negative and -rate-burst must be at least 1")
+	}
+	if cfg.MaxConcurrent < 1 {
+		return cfg, fmt.Errorf("-max-concurrent must be at least 1")
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = cfg.longestRun() + writeSlack
+	} else if cfg.modeAllowed(modeNative) && cfg.WriteTimeout <= cfg.longestRun() {
+		return cfg, fmt.Errorf("-write-timeout %s must exceed %s, the -queue-timeout plus the native build and -sandbox-timeout", cfg.WriteTimeout, cfg.longestRun())
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return cfg, fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+	for _, origin := range strings.Split(*origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+		}
+	}
+
+	return cfg, nil
+}
+
+// applyEnv sets every flag not given on the command line from its
+// environment variable, if present
+func applyEnv(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || err != nil {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// originAllowed reports whether a browser on origin may call the API
+func (c serverConfig) originAllowed(origin string) bool {
+	for _, o := range c.CORSOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// longestRun is how long a native trace request may take before its
+// response is written: waiting for a slot, building, then running
+func (c serverConfig) longestRun() time.Duration {
+	return c.QueueTimeout + executor.NativeBuildTimeout + c.Sandbox.Timeout
+}
+
+func (c serverConfig) modeAllowed(mode string) bool {
+	for _, m := range c.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTimeout(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		// 2s queue + 30s build + 10s run, with room to write the response
+		{nil, 52 * time.Second, false},
+		{[]string{"-sandbox-timeout", "60s"}, 102 * time.Second, false},
+		{[]string{"-write-timeout", "45s"}, 45 * time.Second, false},
+		{[]string{"-modes", "interpreter,native", "-write-timeout", "40s"}, 0, true},
+		{[]string{"-modes", "interpreter,native", "-write-timeout", "43s"}, 43 * time.Second, false},
+	}
+	for _, tt := range tests {
+		cfg, err := loadConfig(tt.args)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "-write-timeout") {
+				t.Errorf("loadConfig(%q) error = %v, want a -write-timeout error", tt.args, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("loadConfig(%q): %v", tt.args, err)
+			continue
+		}
+		if cfg.WriteTimeout != tt.want {
+			t.Errorf("loadConfig(%q).WriteTimeout = %s, want %s", tt.args, cfg.WriteTimeout, tt.want)
+		}
+	}
+}
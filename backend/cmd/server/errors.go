@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goflow/visualizer/internal/api"
+)
+
+// isV1 reports whether the request came in on a versioned route
+func isV1(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/v1/")
+}
+
+// apiPrefix is the path prefix of the route family the request used, so
+// links handed back keep to the same API version
+func apiPrefix(r *http.Request) string {
+	if isV1(r) {
+		return "/api/v1"
+	}
+	return "/api"
+}
+
+// writeError sends e in the shape of the route's API version: an error
+// object under /api/v1, and a plain message, or plain text for a wrong
+// method, on the unversioned aliases. Both send e.Status, except for the
+// failures /api/trace has always reported as a 400.
+func writeError(w http.ResponseWriter, r *http.Request, e *api.Error) {
+	if isV1(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.Status)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Success: false, Error: e})
+		return
+	}
+	if e.Status == http.StatusMethodNotAllowed {
+		http.Error(w, e.Message, e.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(legacyStatus(r, e))
+	json.NewEncoder(w).Encode(api.TraceResponse{
+		Success: false,
+		Error:   e.Message,
+	})
+}
+
+// legacyStatus is the status an unversioned route sends e with. Before
+// /api/v1, /api/trace answered an invalid request and a program that failed
+// to compile or run with a 400; its other failures, and those of the routes
+// added since, keep their own status.
+func legacyStatus(r *http.Request, e *api.Error) int {
+	if r.URL.Path == "/api/trace" && (e.Status == http.StatusBadRequest || e.Status == http.StatusUnprocessableEntity) {
+		return http.StatusBadRequest
+	}
+	return e.Status
+}
+
+// badRequest reports a malformed or invalid request
+func badRequest(w http.ResponseWriter, r *http.Request, msg string) {
+	writeError(w, r, api.NewError(http.StatusBadRequest, api.CodeInvalidRequest, msg))
+}
+
+// internalError reports a failure on the server's side
+func internalError(w http.ResponseWriter, r *http.Request, msg string) {
+	writeError(w, r, api.NewError(http.StatusInternalServerError, api.CodeInternal, msg))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, r, api.NewError(http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, "Method not allowed"))
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, code, msg string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, r, api.NewError(http.StatusTooManyRequests, code, msg))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goflow/visualizer/internal/api"
+)
+
+func TestWriteErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		err      *api.Error
+		status   int
+		envelope bool // v1 error object rather than a plain message
+	}{
+		{"v1 program error", "/api/v1/trace", api.NewError(http.StatusUnprocessableEntity, api.CodeRuntimeError, "boom"), 422, true},
+		{"v1 internal error", "/api/v1/trace", api.NewError(http.StatusInternalServerError, api.CodeInternal, "boom"), 500, true},
+		{"legacy program error", "/api/trace", api.NewError(http.StatusUnprocessableEntity, api.CodeRuntimeError, "boom"), 400, false},
+		{"legacy internal error", "/api/trace", api.NewError(http.StatusInternalServerError, api.CodeInternal, "boom"), 500, false},
+		{"legacy rate limit", "/api/trace", api.NewError(http.StatusTooManyRequests, api.CodeRateLimited, "boom"), 429, false},
+		{"legacy too large", "/api/trace", api.NewError(http.StatusRequestEntityTooLarge, api.CodeInvalidRequest, "boom"), 413, false},
+		{"legacy bad request", "/api/trace", api.NewError(http.StatusBadRequest, api.CodeInvalidRequest, "boom"), 400, false},
+		{"share not found", "/api/share/abc", api.NewError(http.StatusNotFound, api.CodeNotFound, "boom"), 404, false},
+		{"share program error", "/api/share/abc", api.NewError(http.StatusUnprocessableEntity, api.CodeRuntimeError, "boom"), 422, false},
+		{"export internal error", "/api/export/html", api.NewError(http.StatusInternalServerError, api.CodeInternal, "boom"), 500, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, httptest.NewRequest(http.MethodPost, tt.path, nil), tt.err)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+
+			var body struct {
+				Success bool            `json:"success"`
+				Error   json.RawMessage `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if envelope := strings.HasPrefix(string(body.Error), "{"); envelope != tt.envelope {
+				t.Errorf("error = %s, want error object %v", body.Error, tt.envelope)
+			}
+		})
+	}
+}
+
+func TestWriteErrorLegacyMethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	methodNotAllowed(w, httptest.NewRequest(http.MethodGet, "/api/trace", nil), http.MethodPost)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != "Method not allowed" {
+		t.Errorf("body = %q, want the plain-text message", got)
+	}
+	if got := w.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow = %q", got)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"0123abcd"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"0123abcd"`, true},
+		{`W/"0123abcd"`, true},
+		{`"ffff", "0123abcd"`, true},
+		{`*`, true},
+		{`"ffff"`, false},
+		{``, false},
+		{`0123abcd`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
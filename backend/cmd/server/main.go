@@ -1,112 +1,466 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/goflow/visualizer/internal/api"
+	"github.com/goflow/visualizer/internal/cache"
 	"github.com/goflow/visualizer/internal/executor"
+	"github.com/goflow/visualizer/internal/export"
+	"github.com/goflow/visualizer/internal/openapi"
+	"github.com/goflow/visualizer/internal/ratelimit"
+	"github.com/goflow/visualizer/internal/sandbox"
+	"github.com/goflow/visualizer/internal/share"
 	"github.com/goflow/visualizer/internal/tracer"
 )
 
-// TraceRequest represents the incoming request body
-type TraceRequest struct {
-	Code string `json:"code"`
-}
-
-// TraceResponse represents the execution trace response
-type TraceResponse struct {
-	Success     bool              `json:"success"`
-	Error       string            `json:"error,omitempty"`
-	SourceCode  string            `json:"sourceCode"`
-	TotalSteps  int               `json:"totalSteps"`
-	AST         *tracer.ASTResult `json:"ast"`
-	Trace       []tracer.Step     `json:"trace"`
-	FinalOutput string            `json:"finalOutput"`
+// server carries the configuration shared by the handlers
+type server struct {
+	cfg serverConfig
+	// shares is nil when sharing is disabled
+	shares share.Store
+	// cache holds serialized interpreter traces; nil when disabled
+	cache *cache.LRU
+	// limiter is nil when rate limiting is disabled
+	limiter *ratelimit.Limiter
+	runs    *ratelimit.Semaphore
+	metrics *serverMetrics
 }
 
 func main() {
-	mux := http.NewServeMux()
+	// Must run first: when started as the sandbox helper this never returns
+	sandbox.Init()
 
-	// CORS middleware
-	corsHandler := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
 
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			next(w, r)
-		}
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fatal("Invalid configuration", err)
 	}
+	srv := &server{cfg: cfg, runs: ratelimit.NewSemaphore(cfg.MaxConcurrent)}
+	if cfg.RateLimit > 0 {
+		srv.limiter = ratelimit.NewLimiter(cfg.RateLimit, cfg.RateBurst)
+	}
+	if cfg.CacheBytes > 0 {
+		srv.cache = cache.NewLRU(cfg.CacheBytes)
+	}
+	if srv.shares, err = openShareStore(cfg.Share); err != nil {
+		fatal("Failed to open share store", err)
+	}
+	srv.metrics = newServerMetrics(srv)
+
+	mux := http.NewServeMux()
 
 	// Health check endpoint
-	mux.HandleFunc("/health", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/health", srv.observe("/health", srv.cors(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	}))
+	})))
+
+	// Every endpoint lives under /api/v1; the unversioned paths remain as
+	// aliases for existing clients and report errors as plain messages
+	route := func(path, label string, h http.HandlerFunc) {
+		mux.HandleFunc("/api/v1"+path, srv.observe("/api/v1"+label, h))
+		mux.HandleFunc("/api"+path, srv.observe("/api"+label, h))
+	}
 
 	// Main trace endpoint
-	mux.HandleFunc("/api/trace", corsHandler(handleTrace))
+	route("/trace", "/trace", srv.cors(srv.rateLimited(srv.handleTrace)))
 
-	log.Println("GoFlow server starting on :8080")
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	// Server statistics
+	route("/metrics", "/metrics", srv.cors(srv.handleMetrics))
+	mux.HandleFunc("/metrics", srv.handlePrometheus)
+
+	// Shared programs
+	route("/share", "/share", srv.cors(srv.rateLimited(srv.handleShare)))
+	route("/share/", "/share/{id}", srv.cors(srv.rateLimited(srv.handleSharedTrace)))
+
+	// Offline HTML player export
+	route("/export/html", "/export/html", srv.cors(srv.rateLimited(srv.handleExportHTML)))
+
+	// API description, generated from the same types the handlers use
+	spec, err := openapi.Document()
+	if err != nil {
+		fatal("Failed to generate the OpenAPI document", err)
+	}
+	route("/openapi.json", "/openapi.json", srv.cors(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}))
+
+	httpServer := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      mux,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
+
+	// On SIGTERM or Ctrl-C stop accepting connections and let in-flight
+	// traces finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drained := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout.String())
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("shutdown incomplete", "error", err)
+		}
+		close(drained)
+	}()
+
+	slog.Info("GoFlow server starting", "addr", cfg.Addr, "modes", cfg.Modes, "default_mode", cfg.DefaultMode, "tls", cfg.TLSCert != "")
+	if cfg.TLSCert != "" {
+		err = httpServer.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("Server failed", err)
+	}
+
+	// ListenAndServe returns as soon as Shutdown starts; wait for it to drain
+	<-drained
+	if srv.shares != nil {
+		srv.shares.Close()
+	}
+	slog.Info("server stopped")
 }
 
-func handleTrace(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// cors adds the CORS headers for allowed origins and answers preflight
+// requests
+func (s *server) cors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && s.cfg.originAllowed(origin)
+		if allowed {
+			if len(s.cfg.CORSOrigins) == 1 && s.cfg.CORSOrigins[0] == "*" {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		}
+
+		if r.Method == "OPTIONS" {
+			if origin != "" && !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *server) handleTrace(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "mermaid" && format != "dot" && format != "chrome" {
+		badRequest(w, r, "Unknown format: "+format)
 		return
 	}
 
-	var req TraceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, "Invalid request body: "+err.Error())
+	req, ok := s.decodeTraceRequest(w, r)
+	if !ok {
 		return
 	}
 
-	if req.Code == "" {
-		sendError(w, "Code cannot be empty")
+	// Identical code gives an identical trace, so a client that already has
+	// it doesn't need it again
+	etag := ""
+	if key := s.cacheKey(req); key != "" {
+		etag = `"` + cache.Key(key, format)[:32] + `"`
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	result, ok := s.run(w, r, req)
+	if !ok {
 		return
 	}
 
-	// Step 1: Parse and analyze AST
-	astResult, err := tracer.ParseAST(req.Code)
+	var body []byte
+	var contentType string
+	var err error
+	if format == "json" {
+		body, err = result.JSON()
+		contentType = "application/json"
+	} else {
+		var response *api.TraceResponse
+		if response, err = result.Response(); err == nil {
+			switch format {
+			case "mermaid":
+				body, contentType = []byte(export.Mermaid(response.AST, response.Trace)), "text/plain; charset=utf-8"
+			case "dot":
+				body, contentType = []byte(export.DOT(response.AST, response.Trace)), "text/vnd.graphviz; charset=utf-8"
+			case "chrome":
+				body, err = export.ChromeTrace(response.Trace)
+				contentType = "application/json"
+			}
+		}
+	}
 	if err != nil {
-		sendError(w, "Parse error: "+err.Error())
+		internalError(w, r, err.Error())
 		return
 	}
 
-	// Step 2: Execute using simple AST-based executor (more reliable for visualization)
-	trace, output, err := executor.ExecuteSimple(req.Code)
+	w.Header().Set("Content-Type", contentType)
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleExportHTML traces the request and returns the offline HTML player
+// as a download
+func (s *server) handleExportHTML(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeTraceRequest(w, r)
+	if !ok {
+		return
+	}
+	result, ok := s.run(w, r, req)
+	if !ok {
+		return
+	}
+	response, err := result.Response()
 	if err != nil {
-		sendError(w, "Execution error: "+err.Error())
+		internalError(w, r, err.Error())
 		return
 	}
 
-	response := TraceResponse{
-		Success:     true,
-		SourceCode:  req.Code,
-		TotalSteps:  len(trace),
-		AST:         astResult,
-		Trace:       trace,
-		FinalOutput: output,
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="goflow-trace.html"`)
+	if err := export.HTML(w, response, "GoFlow trace"); err != nil {
+		slog.Warn("HTML export failed", "request_id", requestID(r.Context()), "error", err)
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// decodeTraceRequest reads and validates a TraceRequest body. On failure the
+// error response has already been written and ok is false.
+func (s *server) decodeTraceRequest(w http.ResponseWriter, r *http.Request) (req api.TraceRequest, ok bool) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return req, false
+	}
+
+	// Leave room for the JSON around the code
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.cfg.MaxCodeBytes)+4096)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, s.codeTooLarge(s.cfg.MaxCodeBytes))
+			return req, false
+		}
+		badRequest(w, r, "Invalid request body: "+err.Error())
+		return req, false
+	}
+
+	if req.Code == "" {
+		badRequest(w, r, "Code cannot be empty")
+		return req, false
+	}
+	if len(req.Code) > s.cfg.MaxCodeBytes {
+		writeError(w, r, s.codeTooLarge(s.cfg.MaxCodeBytes))
+		return req, false
+	}
+	return req, true
+}
+
+// traced is a successful trace. It comes either from running the program or
+// from the cache, in which case only its JSON encoding is at hand.
+type traced struct {
+	response *api.TraceResponse
+	body     []byte
+}
+
+// Response returns the trace, decoding the cached JSON if needed
+func (t *traced) Response() (*api.TraceResponse, error) {
+	if t.response == nil {
+		var response api.TraceResponse
+		if err := json.Unmarshal(t.body, &response); err != nil {
+			return nil, fmt.Errorf("cached trace error: %w", err)
+		}
+		t.response = &response
+	}
+	return t.response, nil
+}
+
+// JSON returns the trace as the /api/trace response body
+func (t *traced) JSON() ([]byte, error) {
+	if t.body == nil {
+		body, err := json.Marshal(t.response)
+		if err != nil {
+			return nil, fmt.Errorf("trace encoding error: %w", err)
+		}
+		t.body = body
+	}
+	return t.body, nil
+}
+
+func (s *server) resolveMode(mode string) string {
+	if mode == "" {
+		return s.cfg.DefaultMode
+	}
+	return mode
+}
+
+// cacheKey identifies the trace for req, or is "" when it can't be cached.
+// Only interpreter runs are cached: native programs may read the clock or
+// random numbers and trace differently every time.
+func (s *server) cacheKey(req api.TraceRequest) string {
+	mode := s.resolveMode(req.Mode)
+	if s.cache == nil || mode != modeInterpreter || !s.cfg.modeAllowed(mode) {
+		return ""
+	}
+	return cache.Key("interpreter", executor.Version, req.Code)
 }
 
-func sendError(w http.ResponseWriter, msg string) {
+// run executes a decoded request, or serves it from the cache. On failure the
+// error response has already been written and ok is false.
+func (s *server) run(w http.ResponseWriter, r *http.Request, req api.TraceRequest) (result *traced, ok bool) {
+	// Execute using the AST-based interpreter (more reliable for visualization)
+	// or, when asked for, by compiling and running the instrumented program
+	mode := s.resolveMode(req.Mode)
+	if !s.cfg.modeAllowed(mode) {
+		writeError(w, r, api.NewError(http.StatusBadRequest, api.CodeModeNotEnabled, "Execution mode not enabled: "+mode))
+		return nil, false
+	}
+
+	key := s.cacheKey(req)
+	if key != "" {
+		if body, ok := s.cache.Get(key); ok {
+			s.metrics.traces.Inc(mode, outcomeCached)
+			return &traced{body: body}, true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.QueueTimeout)
+	acquired := s.runs.Acquire(ctx)
+	cancel()
+	if !acquired {
+		s.metrics.traces.Inc(mode, outcomeRejected)
+		s.metrics.limitHits.Inc("concurrency")
+		tooManyRequests(w, r, time.Second, api.CodeServerBusy, "Server is busy, try again shortly")
+		return nil, false
+	}
+	defer s.runs.Release()
+
+	execute := executor.ExecuteSimple
+	if mode == modeNative {
+		execute = func(code string) ([]tracer.Step, string, error) {
+			return executor.ExecuteNative(code, s.cfg.Sandbox)
+		}
+	}
+
+	start := time.Now()
+	response, err := api.Trace(req.Code, execute)
+	elapsed := time.Since(start)
+	s.recordTrace(r, req, mode, response, err, elapsed)
+	if err != nil {
+		writeError(w, r, err.(*api.Error))
+		return nil, false
+	}
+
+	result = &traced{response: response}
+	if key != "" {
+		if body, err := result.JSON(); err == nil {
+			s.cache.Put(key, body)
+		}
+	}
+	return result, true
+}
+
+// handleMetrics reports server statistics as JSON
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := api.ServerStats{Executions: s.runs.Stats()}
+	if s.limiter != nil {
+		limiter := s.limiter.Stats()
+		stats.RateLimit = &limiter
+	}
+	if s.cache != nil {
+		cache := s.cache.Stats()
+		stats.Cache = &cache
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(TraceResponse{
-		Success: false,
-		Error:   msg,
-	})
+	json.NewEncoder(w).Encode(stats)
+}
+
+// rateLimited turns away clients that exceed the per-client rate limit
+func (s *server) rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter != nil {
+			if ok, wait := s.limiter.Allow(s.clientIP(r)); !ok {
+				s.metrics.limitHits.Inc("rate")
+				tooManyRequests(w, r, wait, api.CodeRateLimited, "Too many requests, slow down")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// clientIP identifies the client for rate limiting. Behind a proxy the
+// connection comes from the proxy, so the address is taken from the last
+// entry of the trusted header: the one the proxy itself added, which the
+// client cannot forge.
+func (s *server) clientIP(r *http.Request) string {
+	if h := s.cfg.TrustedProxyHeader; h != "" {
+		if values := r.Header.Values(h); len(values) > 0 {
+			entries := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// codeTooLarge reports a program over the size limit
+func (s *server) codeTooLarge(limit int) *api.Error {
+	return api.NewError(http.StatusRequestEntityTooLarge, api.CodeCodeTooLarge, fmt.Sprintf("Code exceeds the limit of %d bytes", limit))
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/goflow/visualizer/internal/api"
+	"github.com/goflow/visualizer/internal/metrics"
+	"github.com/goflow/visualizer/internal/sandbox"
+)
+
+// Trace outcomes used as metric labels and in logs
+const (
+	outcomeSuccess      = "success"
+	outcomeCached       = "cached"
+	outcomeParseError   = "parse_error"
+	outcomeTypeError    = "type_error"
+	outcomeRuntimeError = "runtime_error"
+	outcomeLimit        = "limit"
+	outcomeRejected     = "rejected"
+)
+
+// serverMetrics are the series served on /metrics
+type serverMetrics struct {
+	registry  *metrics.Registry
+	requests  *metrics.Counter
+	traces    *metrics.Counter
+	errors    *metrics.Counter
+	limitHits *metrics.Counter
+	duration  *metrics.Histogram
+	steps     *metrics.Histogram
+}
+
+func newServerMetrics(s *server) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: r,
+		requests: r.NewCounter("goflow_http_requests_total",
+			"HTTP requests by route and status code.", "route", "code"),
+		traces: r.NewCounter("goflow_traces_total",
+			"Trace requests by execution mode and outcome.", "mode", "outcome"),
+		errors: r.NewCounter("goflow_trace_errors_total",
+			"Programs that failed, by the phase that failed: parse, typecheck or runtime.", "phase"),
+		limitHits: r.NewCounter("goflow_limit_hits_total",
+			"Requests stopped by a limit: sandbox limits, rate limit or concurrency cap.", "limit"),
+		duration: r.NewHistogram("goflow_execution_duration_seconds",
+			"Time to parse and execute a program.",
+			[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, "mode"),
+		steps: r.NewHistogram("goflow_trace_steps",
+			"Steps in successful traces.",
+			[]float64{10, 50, 100, 500, 1000, 5000, 10000}, "mode"),
+	}
+
+	r.NewGaugeFunc("goflow_executions_in_flight", "Programs executing right now.",
+		func() float64 { return float64(s.runs.Stats().InFlight) })
+	r.NewGaugeFunc("goflow_executions_limit", "Programs allowed to execute at once.",
+		func() float64 { return float64(s.runs.Stats().Limit) })
+	if s.limiter != nil {
+		r.NewGaugeFunc("goflow_ratelimit_clients", "Clients with a partly used rate-limit bucket.",
+			func() float64 { return float64(s.limiter.Stats().Clients) })
+	}
+	if s.cache != nil {
+		r.NewGaugeFunc("goflow_cache_bytes", "Bytes of traces in the cache.",
+			func() float64 { return float64(s.cache.Stats().Bytes) })
+		r.NewGaugeFunc("goflow_cache_budget_bytes", "Byte budget of the trace cache.",
+			func() float64 { return float64(s.cache.Stats().Budget) })
+		r.NewGaugeFunc("goflow_cache_entries", "Traces in the cache.",
+			func() float64 { return float64(s.cache.Stats().Entries) })
+		r.NewCounterFunc("goflow_cache_hits_total", "Trace cache hits.",
+			func() float64 { return float64(s.cache.Stats().Hits) })
+		r.NewCounterFunc("goflow_cache_misses_total", "Trace cache misses.",
+			func() float64 { return float64(s.cache.Stats().Misses) })
+		r.NewCounterFunc("goflow_cache_evictions_total", "Traces evicted to stay within the budget.",
+			func() float64 { return float64(s.cache.Stats().Evictions) })
+	}
+	return m
+}
+
+// handlePrometheus serves the metrics in Prometheus text format
+func (s *server) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	s.metrics.registry.WriteText(w)
+}
+
+type requestIDKey struct{}
+
+// requestID returns the ID the observe middleware gave the request
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder remembers the status code written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// observe gives each request an ID, echoed in X-Request-ID, counts it by
+// route and status and logs it when done
+func (s *server) observe(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		s.metrics.requests.Inc(route, strconv.Itoa(rec.status))
+		slog.Info("request",
+			"request_id", id,
+			"method", r.Method,
+			"route", route,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client", s.clientIP(r),
+		)
+	}
+}
+
+// recordTrace updates the trace metrics and logs the run. Slow runs are
+// logged with their code so they can be reproduced.
+func (s *server) recordTrace(r *http.Request, req api.TraceRequest, mode string, resp *api.TraceResponse, err error, elapsed time.Duration) {
+	outcome := outcomeSuccess
+	var apiErr *api.Error
+	var limit *sandbox.LimitError
+	switch {
+	case err == nil:
+	case errors.As(err, &limit):
+		outcome = outcomeLimit
+		s.metrics.limitHits.Inc(string(limit.Limit))
+	case errors.As(err, &apiErr) && apiErr.Phase == api.PhaseParse:
+		outcome = outcomeParseError
+		s.metrics.errors.Inc("parse")
+	case errors.As(err, &apiErr) && apiErr.Phase == api.PhaseTypecheck:
+		outcome = outcomeTypeError
+		s.metrics.errors.Inc("typecheck")
+	default:
+		outcome = outcomeRuntimeError
+		s.metrics.errors.Inc("runtime")
+	}
+
+	s.metrics.traces.Inc(mode, outcome)
+	s.metrics.duration.Observe(elapsed.Seconds(), mode)
+	steps := 0
+	if err == nil {
+		steps = resp.TotalSteps
+		s.metrics.steps.Observe(float64(steps), mode)
+	}
+
+	sum := sha256.Sum256([]byte(req.Code))
+	attrs := []any{
+		"request_id", requestID(r.Context()),
+		"mode", mode,
+		"outcome", outcome,
+		"duration_ms", elapsed.Milliseconds(),
+		"steps", steps,
+		"code_bytes", len(req.Code),
+		"code_sha256", hex.EncodeToString(sum[:8]),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+
+	if s.cfg.SlowTrace > 0 && elapsed >= s.cfg.SlowTrace {
+		slog.Warn("slow trace", append(attrs, "code", req.Code)...)
+		return
+	}
+	slog.Info("trace", attrs...)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goflow/visualizer/internal/api"
+	"github.com/goflow/visualizer/internal/executor"
+	"github.com/goflow/visualizer/internal/share"
+)
+
+func openShareStore(cfg shareConfig) (share.Store, error) {
+	switch cfg.Store {
+	case "fs":
+		return share.NewFileStore(cfg.Path)
+	case "kv":
+		return share.OpenKVStore(cfg.Path)
+	default:
+		return nil, nil
+	}
+}
+
+// handleShare saves a program and returns its short ID. Sharing the same
+// code and options again returns the same ID.
+func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.shares == nil {
+		writeError(w, r, sharingDisabled())
+		return
+	}
+
+	// Leave room for the JSON around the code
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.cfg.Share.MaxCodeBytes)+4096)
+	var req api.ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, s.codeTooLarge(s.cfg.Share.MaxCodeBytes))
+			return
+		}
+		badRequest(w, r, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Code == "" {
+		badRequest(w, r, "Code cannot be empty")
+		return
+	}
+	if len(req.Code) > s.cfg.Share.MaxCodeBytes {
+		writeError(w, r, s.codeTooLarge(s.cfg.Share.MaxCodeBytes))
+		return
+	}
+
+	ttl := s.cfg.Share.TTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			badRequest(w, r, "Invalid ttl: "+req.TTL)
+			return
+		}
+		ttl = d
+	}
+	if max := s.cfg.Share.MaxTTL; max > 0 && (ttl == 0 || ttl > max) {
+		ttl = max
+	}
+
+	// Run it now so the link can be served without executing again
+	result, ok := s.run(w, r, req.TraceRequest)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	entry := &share.Entry{
+		ID:        share.ID(req.TraceRequest),
+		Request:   req.TraceRequest,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(ttl)
+	}
+	if trace, err := result.JSON(); err == nil {
+		s.keepTrace(entry, trace)
+	}
+
+	// Re-sharing never shortens the life of a link that's already out there
+	if existing, err := s.shares.Get(entry.ID); err == nil && !existing.Expired(now) {
+		entry.CreatedAt = existing.CreatedAt
+		if existing.ExpiresAt.IsZero() || existing.ExpiresAt.After(entry.ExpiresAt) && !entry.ExpiresAt.IsZero() {
+			entry.ExpiresAt = existing.ExpiresAt
+		}
+	}
+
+	if err := s.shares.Put(entry); err != nil {
+		slog.Error("share failed", "request_id", requestID(r.Context()), "error", err)
+		internalError(w, r, "Could not save the shared program")
+		return
+	}
+
+	resp := api.ShareResponse{ID: entry.ID, URL: apiPrefix(r) + "/share/" + entry.ID}
+	if !entry.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &entry.ExpiresAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSharedTrace returns the trace of a shared program, from the stored
+// copy when there is one from the current executor and by executing it again
+// otherwise
+func (s *server) handleSharedTrace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	if s.shares == nil {
+		writeError(w, r, sharingDisabled())
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, apiPrefix(r)+"/share/")
+	entry, err := s.shares.Get(id)
+	if errors.Is(err, share.ErrNotFound) {
+		writeError(w, r, api.NewError(http.StatusNotFound, api.CodeNotFound, "Shared program not found: "+id))
+		return
+	}
+	if err != nil {
+		slog.Error("share lookup failed", "request_id", requestID(r.Context()), "id", id, "error", err)
+		internalError(w, r, "Could not load the shared program")
+		return
+	}
+	if entry.Expired(time.Now()) {
+		s.shares.Delete(id)
+		writeError(w, r, api.NewError(http.StatusNotFound, api.CodeNotFound, "Shared program has expired: "+id))
+		return
+	}
+
+	if entry.Trace != nil && entry.Version == executor.Version {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(entry.Trace)
+		return
+	}
+
+	result, ok := s.run(w, r, entry.Request)
+	if !ok {
+		return
+	}
+	body, err := result.JSON()
+	if err != nil {
+		internalError(w, r, err.Error())
+		return
+	}
+
+	// Replace a stale trace so the next request is served from the store
+	if entry.Trace != nil {
+		s.keepTrace(entry, body)
+		if err := s.shares.Put(entry); err != nil {
+			slog.Error("share update failed", "request_id", requestID(r.Context()), "id", id, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// keepTrace stores trace in entry when it is small enough, marked with the
+// executor version that produced it
+func (s *server) keepTrace(entry *share.Entry, trace []byte) {
+	entry.Trace, entry.Version = nil, ""
+	if len(trace) <= s.cfg.Share.MaxTraceBytes {
+		entry.Trace, entry.Version = trace, executor.Version
+	}
+}
+
+func sharingDisabled() *api.Error {
+	return api.NewError(http.StatusNotFound, api.CodeNotFound, "Sharing is not enabled on this server")
+}
@@ -0,0 +1,86 @@
+// Package api holds the trace request and response types shared by the HTTP
+// server and the command-line tool, so both produce the same JSON.
+package api
+
+import (
+	"time"
+
+	"github.com/goflow/visualizer/internal/cache"
+	"github.com/goflow/visualizer/internal/ratelimit"
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// Modes lists the execution backends a TraceRequest can ask for
+var Modes = []string{"interpreter", "native"}
+
+// TraceRequest represents the incoming request body
+type TraceRequest struct {
+	Code string `json:"code"`
+	// Mode selects the execution backend: "interpreter" or "native".
+	// Empty means the server's default mode.
+	Mode string `json:"mode,omitempty"`
+}
+
+// TraceResponse represents the execution trace response. Error is only set
+// by the unversioned endpoints, which report failures as a plain message.
+type TraceResponse struct {
+	Success     bool              `json:"success"`
+	Error       string            `json:"error,omitempty"`
+	SourceCode  string            `json:"sourceCode"`
+	TotalSteps  int               `json:"totalSteps"`
+	AST         *tracer.ASTResult `json:"ast"`
+	Trace       []tracer.Step     `json:"trace"`
+	FinalOutput string            `json:"finalOutput"`
+}
+
+// Executor runs a program and returns its trace and output
+type Executor func(code string) ([]tracer.Step, string, error)
+
+// Trace parses and runs code. Problems with the program come back as an
+// *Error describing the phase that failed.
+func Trace(code string, execute Executor) (*TraceResponse, error) {
+	// Step 1: Parse and analyze AST
+	astResult, err := tracer.ParseAST(code)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	// Step 2: Execute with the chosen backend
+	trace, output, err := execute(code)
+	if err != nil {
+		return nil, executionError(err)
+	}
+
+	return &TraceResponse{
+		Success:     true,
+		SourceCode:  code,
+		TotalSteps:  len(trace),
+		AST:         astResult,
+		Trace:       trace,
+		FinalOutput: output,
+	}, nil
+}
+
+// ShareRequest asks the server to save a program under a short ID
+type ShareRequest struct {
+	TraceRequest
+	// TTL is how long the link stays valid, as a Go duration such as "720h".
+	// Empty means the server's default.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// ShareResponse identifies a saved program
+type ShareResponse struct {
+	ID string `json:"id"`
+	// URL is the path that returns the program's trace
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ServerStats is the body of the server statistics endpoint. RateLimit and
+// Cache are only present when the server has them enabled.
+type ServerStats struct {
+	Executions ratelimit.SemaphoreStats `json:"executions"`
+	RateLimit  *ratelimit.LimiterStats  `json:"rateLimit,omitempty"`
+	Cache      *cache.Stats             `json:"cache,omitempty"`
+}
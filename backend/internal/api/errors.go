@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"go/scanner"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goflow/visualizer/internal/executor"
+	"github.com/goflow/visualizer/internal/sandbox"
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// Phase is the stage of handling a program in which an error occurred
+type Phase string
+
+const (
+	PhaseParse     Phase = "parse"
+	PhaseTypecheck Phase = "typecheck"
+	PhaseExecute   Phase = "execute"
+	PhaseLimit     Phase = "limit"
+)
+
+// Error codes: stable identifiers clients can switch on
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeCodeTooLarge       = "code_too_large"
+	CodeModeNotEnabled     = "mode_not_enabled"
+	CodeParseError         = "parse_error"
+	CodeTypeError          = "type_error"
+	CodeRuntimeError       = "runtime_error"
+	CodeUnsupported        = "unsupported"
+	CodeLimitExceeded      = "limit_exceeded"
+	CodeRateLimited        = "rate_limited"
+	CodeServerBusy         = "server_busy"
+	CodeSandboxUnavailable = "sandbox_unavailable"
+	CodeNotFound           = "not_found"
+	CodeInternal           = "internal_error"
+)
+
+// Phases lists every Phase in the order programs go through them
+var Phases = []Phase{PhaseParse, PhaseTypecheck, PhaseExecute, PhaseLimit}
+
+// Codes lists every error code
+var Codes = []string{
+	CodeInvalidRequest,
+	CodeMethodNotAllowed,
+	CodeCodeTooLarge,
+	CodeModeNotEnabled,
+	CodeParseError,
+	CodeTypeError,
+	CodeRuntimeError,
+	CodeUnsupported,
+	CodeLimitExceeded,
+	CodeRateLimited,
+	CodeServerBusy,
+	CodeSandboxUnavailable,
+	CodeNotFound,
+	CodeInternal,
+}
+
+// Diagnostic is one problem found in the program
+type Diagnostic struct {
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// Error is the error object of the v1 API. Line and Column repeat the
+// position of the first diagnostic; Phase is set for errors in the program
+// itself rather than in the request.
+type Error struct {
+	Code        string       `json:"code"`
+	Message     string       `json:"message"`
+	Line        int          `json:"line,omitempty"`
+	Column      int          `json:"column,omitempty"`
+	Phase       Phase        `json:"phase,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+
+	// Status is the HTTP status the error is sent with
+	Status int `json:"-"`
+	cause  error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the executor error behind the API error, if any
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// NewError returns an error about the request rather than the program
+func NewError(status int, code, message string) *Error {
+	return &Error{Code: code, Message: message, Status: status}
+}
+
+// ErrorResponse is the body of every failed v1 request
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Error   *Error `json:"error"`
+}
+
+// parseError describes a program that doesn't parse
+func parseError(err error) *Error {
+	e := &Error{
+		Code:    CodeParseError,
+		Message: "Parse error: " + err.Error(),
+		Phase:   PhaseParse,
+		Status:  http.StatusUnprocessableEntity,
+		cause:   err,
+	}
+	var list scanner.ErrorList
+	if errors.As(err, &list) {
+		for _, item := range list {
+			e.Diagnostics = append(e.Diagnostics, Diagnostic{
+				Message: item.Msg,
+				Line:    item.Pos.Line,
+				Column:  item.Pos.Column,
+			})
+		}
+	}
+	e.setPosition()
+	return e
+}
+
+// executionError classifies an error returned by an executor
+func executionError(err error) *Error {
+	e := &Error{
+		Message: "Execution error: " + err.Error(),
+		Status:  http.StatusUnprocessableEntity,
+		cause:   err,
+	}
+
+	var limit *sandbox.LimitError
+	var isolation *sandbox.IsolationError
+	var build *executor.BuildError
+	var eval *executor.EvalError
+	var typeErr *executor.TypeError
+	var unsupported *executor.UnsupportedError
+	switch {
+	case errors.As(err, &limit):
+		e.Code, e.Phase = CodeLimitExceeded, PhaseLimit
+	case errors.As(err, &isolation):
+		// Refused rather than run without the sandbox
+		e.Code, e.Phase, e.Status = CodeSandboxUnavailable, PhaseExecute, http.StatusServiceUnavailable
+	case errors.As(err, &build):
+		e.Code, e.Phase = CodeTypeError, PhaseTypecheck
+		e.Diagnostics = compilerDiagnostics(build.Output)
+	case errors.As(err, &typeErr):
+		e.Code, e.Phase = CodeTypeError, PhaseTypecheck
+		e.Diagnostics = problemDiagnostics(typeErr.Problems)
+	case errors.As(err, &unsupported):
+		// Running it anyway would give a trace with wrong values
+		e.Code, e.Phase = CodeUnsupported, PhaseExecute
+		e.Diagnostics = problemDiagnostics(unsupported.Constructs)
+		for i := range e.Diagnostics {
+			e.Diagnostics[i].Message = "not supported by the interpreter: " + e.Diagnostics[i].Message
+		}
+	case errors.As(err, &eval):
+		e.Code, e.Phase = CodeRuntimeError, PhaseExecute
+		e.Diagnostics = []Diagnostic{{Message: eval.Message, Line: eval.Line, Column: eval.Column}}
+	case errors.Is(err, tracer.ErrNotMain):
+		e.Code, e.Phase = CodeParseError, PhaseParse
+	default:
+		// The executors report problems in the program through the types
+		// above; anything else is a failure on our side
+		e.Code, e.Status = CodeInternal, http.StatusInternalServerError
+	}
+	e.setPosition()
+	return e
+}
+
+func (e *Error) setPosition() {
+	if len(e.Diagnostics) > 0 {
+		e.Line = e.Diagnostics[0].Line
+		e.Column = e.Diagnostics[0].Column
+	}
+}
+
+func problemDiagnostics(problems []executor.Problem) []Diagnostic {
+	diags := make([]Diagnostic, len(problems))
+	for i, p := range problems {
+		diags[i] = Diagnostic{Message: p.Message, Line: p.Line, Column: p.Column}
+	}
+	return diags
+}
+
+// compilerLine matches "main.go:12:5: message" lines of go build output
+var compilerLine = regexp.MustCompile(`^main\.go:(\d+):(?:(\d+):)? (.*)$`)
+
+func compilerDiagnostics(output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		m := compilerLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		d := Diagnostic{Message: m[3]}
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Column, _ = strconv.Atoi(m[2])
+		diags = append(diags, d)
+	}
+	return diags
+}
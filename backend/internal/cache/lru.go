@@ -0,0 +1,117 @@
+// Package cache keeps serialized responses in memory, keyed by a hash of
+// everything that determines them.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// Key hashes the parts that determine a response into a cache key
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		// Separate parts so ("ab", "c") and ("a", "bc") differ
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Stats describes the cache's contents and effectiveness
+type Stats struct {
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Budget    int64  `json:"budget"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+type entry struct {
+	key   string
+	value []byte
+}
+
+// LRU is a least-recently-used cache bounded by the total size of its values
+type LRU struct {
+	mu     sync.Mutex
+	budget int64
+	size   int64
+	order  *list.List // front is most recently used
+	items  map[string]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+// NewLRU returns a cache holding at most budget bytes of values
+func NewLRU(budget int64) *LRU {
+	return &LRU{
+		budget: budget,
+		order:  list.New(),
+		items:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value for key and marks it as recently used
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Put stores value under key, evicting the least recently used entries to
+// stay within the budget. Values larger than the whole budget aren't kept.
+// The cache keeps value, so callers must not modify it afterwards.
+func (c *LRU) Put(key string, value []byte) {
+	size := int64(len(value))
+	if size > c.budget {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		old := el.Value.(*entry)
+		c.size += size - int64(len(old.value))
+		old.value = value
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&entry{key: key, value: value})
+		c.size += size
+	}
+
+	for c.size > c.budget {
+		oldest := c.order.Back()
+		e := oldest.Value.(*entry)
+		c.order.Remove(oldest)
+		delete(c.items, e.key)
+		c.size -= int64(len(e.value))
+		c.evictions++
+	}
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Entries:   len(c.items),
+		Bytes:     c.size,
+		Budget:    c.budget,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
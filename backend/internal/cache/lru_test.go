@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+// keys returns the cached keys from most to least recently used
+func keys(c *LRU) []string {
+	var out []string
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		out = append(out, el.Value.(*entry).key)
+	}
+	return out
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+	// Each op is "put key size" or "get key"
+	type op struct {
+		get  bool
+		key  string
+		size int
+	}
+	tests := []struct {
+		name      string
+		budget    int64
+		ops       []op
+		want      []string
+		evictions uint64
+	}{
+		{
+			name:   "oldest goes first",
+			budget: 3,
+			ops:    []op{{false, "a", 1}, {false, "b", 1}, {false, "c", 1}, {false, "d", 1}},
+			want:   []string{"d", "c", "b"}, evictions: 1,
+		},
+		{
+			name:   "get refreshes",
+			budget: 3,
+			ops:    []op{{false, "a", 1}, {false, "b", 1}, {false, "c", 1}, {true, "a", 0}, {false, "d", 1}},
+			want:   []string{"d", "a", "c"}, evictions: 1,
+		},
+		{
+			name:   "put of an existing key refreshes and resizes",
+			budget: 3,
+			ops:    []op{{false, "a", 1}, {false, "b", 1}, {false, "c", 1}, {false, "a", 2}},
+			want:   []string{"a", "c"}, evictions: 1,
+		},
+		{
+			name:   "large value evicts several",
+			budget: 4,
+			ops:    []op{{false, "a", 1}, {false, "b", 1}, {false, "c", 1}, {false, "d", 3}},
+			want:   []string{"d", "c"}, evictions: 2,
+		},
+		{
+			name:   "value over budget is not kept",
+			budget: 2,
+			ops:    []op{{false, "a", 1}, {false, "b", 3}},
+			want:   []string{"a"}, evictions: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(tt.budget)
+			for _, o := range tt.ops {
+				if o.get {
+					c.Get(o.key)
+				} else {
+					c.Put(o.key, make([]byte, o.size))
+				}
+			}
+			if got := keys(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			stats := c.Stats()
+			if stats.Evictions != tt.evictions {
+				t.Errorf("evictions = %d, want %d", stats.Evictions, tt.evictions)
+			}
+			if stats.Bytes > tt.budget {
+				t.Errorf("bytes = %d over budget %d", stats.Bytes, tt.budget)
+			}
+		})
+	}
+}
+
+func TestLRUHitsAndMisses(t *testing.T) {
+	c := NewLRU(10)
+	c.Put("a", []byte("1"))
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("Get(b) hit")
+	}
+	want := Stats{Entries: 1, Bytes: 1, Budget: 10, Hits: 1, Misses: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+// ETags are derived from Key, so equal requests must reuse the same key and
+// any difference must change it
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		same bool
+	}{
+		{"same parts", []string{"interpreter", "code", "json"}, []string{"interpreter", "code", "json"}, true},
+		{"different format", []string{"code", "json"}, []string{"code", "dot"}, false},
+		{"parts are separated", []string{"ab", "c"}, []string{"a", "bc"}, false},
+		{"empty part counts", []string{"a"}, []string{"a", ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := Key(tt.a...) == Key(tt.b...); same != tt.same {
+				t.Errorf("Key(%q) == Key(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package conformance runs Go programs through both the interpreter and the
+// local Go toolchain and reports where the interpreter diverges from what
+// `go run` prints.
+package conformance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/goflow/visualizer/internal/executor"
+	"github.com/goflow/visualizer/internal/sandbox"
+)
+
+// Status classifies the outcome for one program
+type Status string
+
+const (
+	StatusPass        Status = "pass"
+	StatusMismatch    Status = "mismatch"    // outputs differ
+	StatusUnsupported Status = "unsupported" // the interpreter skipped constructs
+	StatusPanic       Status = "panic"       // the interpreter panicked
+	StatusError       Status = "error"       // the program could not be run by one side
+)
+
+// unorderedDirective marks programs whose output order is not deterministic,
+// such as ranging over a map; their output lines are compared as a multiset
+const unorderedDirective = "// conformance: unordered"
+
+// Result is the outcome for one program
+type Result struct {
+	File        string   `json:"file"`
+	Status      Status   `json:"status"`
+	Expected    string   `json:"expected"`
+	Actual      string   `json:"actual"`
+	Unsupported []string `json:"unsupported,omitempty"`
+	Detail      string   `json:"detail,omitempty"`
+}
+
+// CheckDir checks every .go file in dir, in name order
+func CheckDir(dir string, limits sandbox.Config) ([]Result, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .go files in %s", dir)
+	}
+	sort.Strings(paths)
+
+	results := make([]Result, 0, len(paths))
+	for _, path := range paths {
+		code, err := os.ReadFile(path)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, Check(filepath.Base(path), string(code), limits))
+	}
+	return results, nil
+}
+
+// Check runs one program through the interpreter and the real toolchain
+func Check(name, code string, limits sandbox.Config) Result {
+	result := Result{File: name}
+
+	native, err := executor.RunNative(code, limits)
+	if err != nil {
+		result.Status = StatusError
+		result.Detail = "go: " + err.Error()
+		return result
+	}
+	result.Expected = native.Stdout
+
+	interp, panicMsg, err := executeRecovered(code)
+	switch {
+	case panicMsg != "":
+		result.Status = StatusPanic
+		result.Detail = panicMsg
+		return result
+	case err != nil:
+		result.Status = StatusError
+		result.Detail = "interpreter: " + err.Error()
+		return result
+	}
+	result.Actual = interp.Output
+	for _, u := range interp.Unsupported {
+		result.Unsupported = append(result.Unsupported, u.String())
+	}
+
+	diff := compareOutput(result.Expected, result.Actual, strings.Contains(code, unorderedDirective))
+	switch {
+	case len(result.Unsupported) > 0:
+		result.Status = StatusUnsupported
+		result.Detail = diff
+	case diff != "":
+		result.Status = StatusMismatch
+		result.Detail = diff
+	case native.ExitCode != 0 || interp.Panic != "":
+		// Both must have died of the same panic
+		want, got := firstLine(native.Stderr), ""
+		if interp.Panic != "" {
+			got = "panic: " + interp.Panic
+		}
+		if want != got {
+			result.Status = StatusMismatch
+			result.Detail = fmt.Sprintf("go exited with status %d: %s; interpreter: %s", native.ExitCode, want, got)
+		} else {
+			result.Status = StatusPass
+		}
+	default:
+		result.Status = StatusPass
+	}
+	return result
+}
+
+// executeRecovered runs the interpreter, turning a panic into a message
+func executeRecovered(code string) (result *executor.Result, panicMsg string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicMsg = fmt.Sprintf("%v\n%s", r, debug.Stack())
+		}
+	}()
+	result, err = executor.Execute(code)
+	return result, "", err
+}
+
+// compareOutput returns a description of the first difference, or "" when
+// the outputs agree
+func compareOutput(expected, actual string, unordered bool) string {
+	want := strings.Split(expected, "\n")
+	got := strings.Split(actual, "\n")
+	if unordered {
+		sort.Strings(want)
+		sort.Strings(got)
+	}
+
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w != g || i >= len(want) || i >= len(got) {
+			return fmt.Sprintf("output line %d: want %q, got %q", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// Summary counts results by status
+func Summary(results []Result) map[Status]int {
+	counts := make(map[Status]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	return counts
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// maxJumps bounds how often a goto may jump back within one run of a block,
+// as maxIterations does for loops
+const maxJumps = 100
+
+func (e *simpleExecutor) executeBranch(s *ast.BranchStmt) {
+	line := e.fset.Position(s.Pos()).Line
+	// The statement names the target label, as in break outer
+	statement := s.Tok.String()
+	label := ""
+	if s.Label != nil {
+		label = s.Label.Name
+		statement += " " + label
+	}
+
+	switch s.Tok {
+	case token.BREAK:
+		e.hasBroken = true
+		e.branchLabel = label
+	case token.CONTINUE:
+		e.hasContinued = true
+		e.branchLabel = label
+	case token.GOTO:
+		e.gotoLabel = label
+	case token.FALLTHROUGH:
+		e.hasFallthrough = true
+	}
+	e.addStep(line, s.Tok.String(), statement)
+}
+
+// takeLabel returns the label of the loop or switch starting to execute
+func (e *simpleExecutor) takeLabel() string {
+	label := e.label
+	e.label = ""
+	return label
+}
+
+// targets reports whether the break or continue being executed is aimed
+// at the loop or switch labeled label: an unlabeled one is aimed at the
+// innermost
+func (e *simpleExecutor) targets(label string) bool {
+	return e.branchLabel == "" || e.branchLabel == label
+}
+
+// loopDone is called after each iteration of the loop labeled label. It
+// reports whether the loop ends, consuming a break or continue aimed at it;
+// one aimed at an outer loop, a return or a goto end it too and carry on
+// outward.
+func (e *simpleExecutor) loopDone(label string) bool {
+	switch {
+	case e.hasReturned || e.gotoLabel != "":
+		return true
+	case e.hasBroken:
+		if e.targets(label) {
+			e.hasBroken = false
+			e.branchLabel = ""
+		}
+		return true
+	case e.hasContinued:
+		if !e.targets(label) {
+			return true
+		}
+		e.hasContinued = false
+		e.branchLabel = ""
+	}
+	return false
+}
+
+// labelIndex returns the index of the statement labeled label in stmts, or -1
+func labelIndex(stmts []ast.Stmt, label string) int {
+	for i, stmt := range stmts {
+		if l, ok := stmt.(*ast.LabeledStmt); ok && l.Label.Name == label {
+			return i
+		}
+	}
+	return -1
+}
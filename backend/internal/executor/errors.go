@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// EvalError reports an expression the interpreter can't evaluate, such as
+// an operator applied to a kind of value it doesn't model. Unlike a panic of
+// the program, it means the trace would be wrong, so the run stops.
+type EvalError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("line %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Problem is one error in the program, at a position
+type Problem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// TypeError reports a program that doesn't type-check. It isn't run, since
+// the interpreter relies on the types to evaluate it, and in native mode the
+// instrumented program could compile where the original doesn't.
+type TypeError struct {
+	Problems []Problem
+}
+
+func (e *TypeError) Error() string {
+	msg := "type error: " + e.Problems[0].String()
+	if n := len(e.Problems) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// UnsupportedError reports a program using constructs the interpreter
+// doesn't implement. Its trace would show wrong values, so none is given.
+type UnsupportedError struct {
+	Constructs []Problem
+}
+
+func (e *UnsupportedError) Error() string {
+	msg := "unsupported: " + e.Constructs[0].String()
+	if n := len(e.Constructs) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// fail stops the run with an EvalError positioned at node
+func (e *simpleExecutor) fail(node ast.Node, format string, args ...interface{}) {
+	pos := e.fset.Position(node.Pos())
+	panic(&EvalError{Line: pos.Line, Column: pos.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// typeName names the type of an interpreter value for error messages
+func typeName(v interface{}) string {
+	if v == nil {
+		return "nil"
+	}
+	return cleanTypeName(fmt.Sprintf("%T", v))
+}
@@ -7,93 +7,260 @@ import (
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/goflow/visualizer/internal/tracer"
 )
 
+// Version identifies the interpreter's behaviour. Bump it whenever a change
+// alters the trace produced for some program, so traces cached by an older
+// build are not served for it.
+const Version = "18"
+
 // CallFrame represents a function call on the call stack
 type CallFrame struct {
 	FuncName       string
-	SavedVars      map[string]interface{}
-	SavedTypes     map[string]string
-	SavedScope     []string
+	SavedScope     *scope
 	SavedReturned  bool
 	SavedReturnVal interface{}
+	SavedTypeArgs  map[*types.TypeParam]types.Type
+	SavedResults   *ast.FieldList
+}
+
+// Result is the full outcome of an interpreter run
+type Result struct {
+	Steps  []tracer.Step
+	Output string
+	// Unsupported lists constructs the interpreter skipped or could not
+	// evaluate, in the order they were met
+	Unsupported []Problem
+	// Panic is the message of the run-time panic that ended the program,
+	// such as "runtime error: integer divide by zero"
+	Panic string
 }
 
 // ExecuteSimple executes Go code by parsing the AST and simulating execution
-// This approach gives us full control over variable tracking and step generation
+// This approach gives us full control over variable tracking and step generation.
+// A program using constructs the interpreter can't handle gives an
+// *UnsupportedError rather than a trace with wrong values.
 func ExecuteSimple(code string) ([]tracer.Step, string, error) {
+	result, err := Execute(code)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(result.Unsupported) > 0 {
+		return nil, "", &UnsupportedError{Constructs: result.Unsupported}
+	}
+	// As with a native run, the panic is part of what the program printed
+	output := result.Output
+	if result.Panic != "" {
+		output += panicOutput(result.Panic)
+	}
+	return result.Steps, output, nil
+}
+
+// Execute runs code like ExecuteSimple and also reports what it couldn't handle
+func Execute(code string) (*Result, error) {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "main.go", code, parser.ParseComments)
 	if err != nil {
-		return nil, "", fmt.Errorf("parse error: %w", err)
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	info, err := typeCheck(fset, file, &stubImporter{fset: fset, packages: make(map[string]*types.Package)})
+	imports := unsupportedImports(file)
+	// Errors in a program using packages the interpreter doesn't have are
+	// mostly about those packages; the imports are reported instead
+	if err != nil && len(imports) == 0 {
+		return nil, err
 	}
 
 	executor := &simpleExecutor{
-		fset:           fset,
-		steps:          make([]tracer.Step, 0),
-		variables:      make(map[string]interface{}),
-		varTypes:       make(map[string]string),
-		scopeStack:     []string{"main"},
-		output:         &bytes.Buffer{},
-		stepIndex:      0,
-		loopIterations: make(map[string]int),
-		loopCounter:    0,
-		functions:      make(map[string]*ast.FuncDecl),
-		callStack:      []CallFrame{{FuncName: "main"}},
-		maxCallDepth:   50,
+		fset:            fset,
+		info:            info,
+		blockIDs:        numberBlocks(file),
+		steps:           make([]tracer.Step, 0),
+		pkg:             newScope(nil, "package"),
+		output:          &bytes.Buffer{},
+		stepIndex:       0,
+		loopIterations:  make(map[string]int),
+		loopCounter:     0,
+		functions:       make(map[string]*ast.FuncDecl),
+		methods:         make(map[*types.Func]*ast.FuncDecl),
+		funcLits:        nameFuncLits(file),
+		maxCallDepth:    50,
+		seenUnsupported: make(map[Problem]bool),
+	}
+
+	for _, spec := range imports {
+		executor.unsupported(spec, "import of package "+spec.Path.Value)
 	}
 
 	// Pre-scan: register all function declarations
+	var main *ast.FuncDecl
+	var inits []*ast.FuncDecl
 	for _, decl := range file.Decls {
-		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name != "main" {
-			executor.functions[fn.Name.Name] = fn
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			switch {
+			case d.Recv != nil:
+				executor.declareMethod(d)
+			case d.Name.Name == "main":
+				main = d
+			case d.Name.Name == "init":
+				// init functions can't be called, only run before main
+				inits = append(inits, d)
+			default:
+				executor.functions[d.Name.Name] = d
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.TYPE {
+				executor.declareTypes(d)
+			}
 		}
 	}
 
-	// Find and execute main function
-	for _, decl := range file.Decls {
-		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "main" {
-			if fn.Body != nil {
-				executor.executeBlock(fn.Body.List)
-			}
+	var panicMsg string
+	if main != nil {
+		if panicMsg, err = executor.runMain(file, inits, main); err != nil {
+			return nil, err
 		}
 	}
 
-	return executor.steps, executor.output.String(), nil
+	return &Result{
+		Steps:       executor.steps,
+		Output:      executor.output.String(),
+		Unsupported: executor.unsupportedList,
+		Panic:       panicMsg,
+	}, nil
+}
+
+// runMain initializes the package, then runs the init functions and main in
+// the order the runtime does. A run-time panic of the program ends it with a
+// panic step and returns the panic message; an expression the interpreter
+// can't evaluate ends it with an *EvalError.
+func (e *simpleExecutor) runMain(file *ast.File, inits []*ast.FuncDecl, main *ast.FuncDecl) (panicMsg string, err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case goPanic:
+			panicMsg = r.msg
+			e.addStepWithOutput(e.line, "panic", "panic: "+r.msg, panicOutput(r.msg))
+		case *EvalError:
+			err = r
+		default:
+			panic(r)
+		}
+	}()
+	e.initPackage(file)
+	for _, fn := range inits {
+		e.runFunc(fn)
+	}
+	e.runFunc(main)
+	return "", nil
+}
+
+// panicOutput is what the Go runtime prints to stderr for a panic
+func panicOutput(msg string) string {
+	return "panic: " + msg + "\n"
 }
 
 type simpleExecutor struct {
-	fset           *token.FileSet
-	steps          []tracer.Step
-	variables      map[string]interface{}
-	varTypes       map[string]string
-	scopeStack     []string
+	fset *token.FileSet
+	// info holds the types and constant values the type checker found
+	info *types.Info
+	// blockIDs numbers the if, switch and explicit blocks for their scope names
+	blockIDs map[ast.Node]int
+	steps    []tracer.Step
+	// pkg holds the package-level variables and constants; scope is the
+	// innermost block of the function being executed
+	pkg            *scope
+	scope          *scope
 	output         *bytes.Buffer
 	stepIndex      int
 	loopIterations map[string]int
 	loopCounter    int
 	functions      map[string]*ast.FuncDecl
+	// methods holds the method declarations by their object
+	methods map[*types.Func]*ast.FuncDecl
+	// funcLits names the function literals, as main.func1
+	funcLits       map[*ast.FuncLit]string
 	callStack      []CallFrame
 	maxCallDepth   int
 	returnValue    interface{}
 	hasReturned    bool
 	hasBroken      bool
 	hasContinued   bool
+	hasFallthrough bool
+	// branchLabel is the label of the break or continue being executed,
+	// empty when it has none
+	branchLabel string
+	// gotoLabel is the label a goto jumps to, until the block holding it
+	// is reached
+	gotoLabel string
+	// label is the label of the loop or switch about to execute
+	label string
+	// line is the line of the statement being executed
+	line int
+	// typeArgs are the type arguments of the generic function being
+	// executed, by type parameter
+	typeArgs map[*types.TypeParam]types.Type
+	// results are the named results of the function being executed, which
+	// a bare return returns
+	results *ast.FieldList
+
+	unsupportedList []Problem
+	seenUnsupported map[Problem]bool
+}
+
+// unsupported records a construct the interpreter skipped, once per position
+func (e *simpleExecutor) unsupported(node ast.Node, what string) {
+	pos := e.fset.Position(node.Pos())
+	entry := Problem{Line: pos.Line, Column: pos.Column, Message: what}
+	if !e.seenUnsupported[entry] {
+		e.seenUnsupported[entry] = true
+		e.unsupportedList = append(e.unsupportedList, entry)
+	}
+}
+
+// nodeKind names an AST node for unsupported reports, e.g. "GoStmt"
+func nodeKind(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
 }
 
 func (e *simpleExecutor) executeBlock(stmts []ast.Stmt) {
-	for _, stmt := range stmts {
-		if e.hasReturned || e.hasBroken || e.hasContinued {
+	jumps := 0
+	for i := 0; i < len(stmts); i++ {
+		if e.hasReturned || e.hasBroken || e.hasContinued || e.hasFallthrough {
 			return
 		}
-		e.executeStmt(stmt)
+		e.executeStmt(stmts[i])
+
+		if e.gotoLabel != "" {
+			// A goto leaves every block until the one declaring its label
+			target := labelIndex(stmts, e.gotoLabel)
+			if target < 0 {
+				return
+			}
+			e.gotoLabel = ""
+			// Like a loop, a goto jumping back is cut off after
+			// maxJumps iterations
+			if target <= i {
+				if jumps++; jumps >= maxJumps {
+					return
+				}
+			}
+			i = target - 1
+		}
 	}
 }
 
 func (e *simpleExecutor) executeStmt(stmt ast.Stmt) {
+	e.line = e.fset.Position(stmt.Pos()).Line
 	switch s := stmt.(type) {
 	case *ast.AssignStmt:
 		e.executeAssign(s)
@@ -115,69 +282,231 @@ func (e *simpleExecutor) executeStmt(stmt ast.Stmt) {
 		e.executeSwitch(s)
 	case *ast.BranchStmt:
 		e.executeBranch(s)
+	case *ast.LabeledStmt:
+		switch s.Stmt.(type) {
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt:
+			// break and continue can name the statement
+			e.label = s.Label.Name
+		}
+		e.executeStmt(s.Stmt)
+	case *ast.BlockStmt:
+		e.pushScope(fmt.Sprintf("block_%d", e.blockIDs[s]))
+		e.executeBlock(s.List)
+		e.popScope()
+	default:
+		e.unsupported(stmt, "statement "+nodeKind(stmt))
 	}
 }
 
 func (e *simpleExecutor) executeAssign(s *ast.AssignStmt) {
 	line := e.fset.Position(s.Pos()).Line
+	e.applyAssign(s)
+	e.addStep(line, "assign", e.getStatementText(s))
+}
 
-	// Evaluate RHS and assign to LHS
+func (e *simpleExecutor) applyAssign(s *ast.AssignStmt) {
+	if s.Tok != token.ASSIGN && s.Tok != token.DEFINE {
+		// x op= y is x = x op y
+		if len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+			return
+		}
+		current := e.evalExpr(s.Lhs[0])
+		operand := e.evalExpr(s.Rhs[0])
+		value := e.evalBinary(current, operand, assignOp(s.Tok))
+		if value == nil {
+			e.fail(s, "operator %s not supported on %s and %s", s.Tok, typeName(current), typeName(operand))
+		}
+		e.store(s.Lhs[0], value)
+		return
+	}
+
+	// Every right-hand side is evaluated before anything is assigned, so
+	// a, b = b, a swaps
+	values := make([]interface{}, len(s.Rhs))
+	if len(s.Lhs) > 1 && len(s.Rhs) == 1 {
+		values = e.evalMulti(s.Rhs[0], len(s.Lhs))
+	} else {
+		for i, rhs := range s.Rhs {
+			values[i] = e.evalExpr(rhs)
+		}
+	}
 	for i, lhs := range s.Lhs {
-		if i < len(s.Rhs) {
-			value := e.evalExpr(s.Rhs[i])
-			
-			switch target := lhs.(type) {
-			case *ast.Ident:
-				// Simple variable assignment: x = value
-				e.variables[target.Name] = value
-				e.varTypes[target.Name] = fmt.Sprintf("%T", value)
-			case *ast.IndexExpr:
-				if ident, ok := target.X.(*ast.Ident); ok {
-					// Map index assignment: m[key] = value
-					if m, ok := e.variables[ident.Name].(map[interface{}]interface{}); ok {
-						idx := e.evalExpr(target.Index)
-						m[idx] = value
-						e.variables[ident.Name] = m
-					} else if arr, ok := e.variables[ident.Name].([]int); ok {
-						// Slice index assignment: arr[i] = value
-						idx := e.evalExpr(target.Index)
-						if idxInt, ok := idx.(int); ok && idxInt >= 0 && idxInt < len(arr) {
-							arr[idxInt] = value.(int)
-							e.variables[ident.Name] = arr
-						}
-					}
-				}
+		if i >= len(values) {
+			break
+		}
+		if ident, ok := lhs.(*ast.Ident); ok && s.Tok == token.DEFINE {
+			e.define(ident, values[i])
+		} else {
+			e.store(lhs, values[i])
+		}
+	}
+}
+
+// assignOp returns the binary operator of a compound assignment such as +=
+func assignOp(tok token.Token) token.Token {
+	return tok - token.ADD_ASSIGN + token.ADD
+}
+
+// store assigns value to a variable, map entry or slice element
+func (e *simpleExecutor) store(lhs ast.Expr, value interface{}) {
+	switch target := lhs.(type) {
+	case *ast.Ident:
+		// Simple variable assignment: x = value
+		if target.Name == "_" {
+			return
+		}
+		owner := e.lookupIdent(target)
+		if owner == nil {
+			owner = e.scope
+		}
+		owner.declare(target.Name, value, e.varType(target, value))
+	case *ast.IndexExpr:
+		collection := e.evalExpr(target.X)
+		idx := e.evalExpr(target.Index)
+		if m, ok := collection.(map[interface{}]interface{}); ok {
+			// Map index assignment: m[key] = value
+			if m == nil {
+				panic(goPanic{msg: "assignment to entry in nil map"})
 			}
+			m[idx] = value
+			return
 		}
+		c := reflect.ValueOf(collection)
+		switch c.Kind() {
+		case reflect.Slice:
+			// Slice elements are shared with every copy of the slice, so
+			// they are set in place, even in grid[i][j] = v
+			i := e.checkIndex(idx, c.Len())
+			c.Index(i).Set(e.assignable(lhs, value, c.Type().Elem()))
+		case reflect.Array:
+			// Array variables and elements are set in place, which slices of
+			// them see
+			i := e.checkIndex(idx, c.Len())
+			if addr, ok := e.addressOf(target.X); ok {
+				addr.Index(i).Set(e.assignable(lhs, value, c.Type().Elem()))
+				return
+			}
+			// Any other array is a value: setting an element makes a new
+			// array, which is stored back where the old one was
+			updated := reflect.New(c.Type()).Elem()
+			updated.Set(c)
+			updated.Index(i).Set(e.assignable(lhs, value, c.Type().Elem()))
+			e.store(target.X, updated.Interface())
+		default:
+			e.unsupported(lhs, "assignment to "+e.exprText(lhs))
+		}
+	case *ast.SelectorExpr, *ast.StarExpr:
+		// Fields are set in place, which pointers to the struct see
+		addr, ok := e.addressOf(lhs)
+		if !ok {
+			e.unsupported(lhs, "assignment to "+e.exprText(lhs))
+			return
+		}
+		addr.Set(e.assignable(lhs, value, addr.Type()))
+	case *ast.ParenExpr:
+		e.store(target.X, value)
+	default:
+		e.unsupported(lhs, "assignment to "+e.exprText(lhs))
 	}
+}
 
-	e.addStep(line, "assign", e.getStatementText(s))
+// checkIndex converts an index of any integer type to int, panicking like
+// Go when it is out of range for length n
+func (e *simpleExecutor) checkIndex(idx interface{}, n int) int {
+	i := intIndex(idx)
+	if i < 0 || i >= n {
+		runtimePanic(fmt.Sprintf("index out of range [%d] with length %d", i, n))
+	}
+	return i
+}
+
+// checkSlice returns the bounds of s[low:high:max] on a string or array of
+// the given length, or a slice of the given capacity, panicking like the
+// program would when they are out of range. limit says which of the two
+// bounds high. nil bounds are the ones the expression leaves out.
+func (e *simpleExecutor) checkSlice(low, high, max interface{}, length, capacity int, limit string) (int, int, int) {
+	bound := func(v interface{}, def int) int {
+		if v == nil {
+			return def
+		}
+		return intIndex(v)
+	}
+
+	if max != nil {
+		mx := intIndex(max)
+		if mx < 0 || mx > capacity {
+			runtimePanic(fmt.Sprintf("slice bounds out of range [::%d] with %s %d", mx, limit, capacity))
+		}
+		hi := bound(high, length)
+		if hi < 0 || hi > mx {
+			runtimePanic(fmt.Sprintf("slice bounds out of range [:%d:%d]", hi, mx))
+		}
+		lo := bound(low, 0)
+		if lo < 0 || lo > hi {
+			runtimePanic(fmt.Sprintf("slice bounds out of range [%d:%d:]", lo, hi))
+		}
+		return lo, hi, mx
+	}
+
+	hi := bound(high, length)
+	if hi < 0 || hi > capacity {
+		runtimePanic(fmt.Sprintf("slice bounds out of range [:%d] with %s %d", hi, limit, capacity))
+	}
+	lo := bound(low, 0)
+	if lo < 0 || lo > hi {
+		runtimePanic(fmt.Sprintf("slice bounds out of range [%d:%d]", lo, hi))
+	}
+	return lo, hi, capacity
+}
+
+// intIndex converts an index of any integer type to int
+func intIndex(idx interface{}) int {
+	v := reflect.ValueOf(idx)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int(v.Uint())
+	}
+	runtimePanic(fmt.Sprintf("invalid index %v", idx))
+	return 0
 }
 
 func (e *simpleExecutor) executeDecl(s *ast.DeclStmt) {
 	line := e.fset.Position(s.Pos()).Line
 
-	if genDecl, ok := s.Decl.(*ast.GenDecl); ok && genDecl.Tok == token.VAR {
+	if genDecl, ok := s.Decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+		e.declareTypes(genDecl)
+	} else if ok && genDecl.Tok != token.VAR && genDecl.Tok != token.CONST {
+		e.unsupported(s, genDecl.Tok.String()+" declaration")
+	} else if ok {
 		for _, spec := range genDecl.Specs {
 			if valueSpec, ok := spec.(*ast.ValueSpec); ok {
 				typeName := ""
 				if valueSpec.Type != nil {
 					typeName = e.getTypeString(valueSpec.Type)
 				}
+				var values []interface{}
+				if len(valueSpec.Names) > 1 && len(valueSpec.Values) == 1 {
+					values = e.evalMulti(valueSpec.Values[0], len(valueSpec.Names))
+				}
 				for i, name := range valueSpec.Names {
 					var value interface{}
-					if i < len(valueSpec.Values) {
+					if c, ok := e.info.Defs[name].(*types.Const); ok {
+						// Including iota and the implicit repetition of
+						// the previous spec's expression
+						value = constValue(c.Val(), c.Type())
+					} else if values != nil {
+						value = values[i]
+					} else if i < len(valueSpec.Values) {
 						value = e.evalExpr(valueSpec.Values[i])
+					} else if obj := e.info.Defs[name]; obj != nil {
+						value = zeroOf(e.concrete(obj.Type()))
 					} else {
 						// Zero value based on type
 						value = e.zeroValue(typeName)
 					}
-					e.variables[name.Name] = value
-					if typeName != "" {
-						e.varTypes[name.Name] = typeName
-					} else {
-						e.varTypes[name.Name] = fmt.Sprintf("%T", value)
-					}
+					e.declare(name, value)
 				}
 			}
 		}
@@ -188,10 +517,13 @@ func (e *simpleExecutor) executeDecl(s *ast.DeclStmt) {
 
 func (e *simpleExecutor) executeFor(s *ast.ForStmt) {
 	line := e.fset.Position(s.Pos()).Line
+	label := e.takeLabel()
 	e.loopCounter++
 	loopID := fmt.Sprintf("for_%d", e.loopCounter)
 
-	// Execute init
+	// Variables declared by init belong to the loop, not the enclosing block
+	e.pushScope(loopID)
+	defer e.popScope()
 	if s.Init != nil {
 		e.executeStmt(s.Init)
 	}
@@ -203,65 +535,93 @@ func (e *simpleExecutor) executeFor(s *ast.ForStmt) {
 	iteration := 0
 
 	for iteration < maxIterations {
-		if e.hasReturned {
-			break
-		}
-
 		// Check condition
+		condResult := true
 		if s.Cond != nil {
 			condValue := e.evalExpr(s.Cond)
 			if b, ok := condValue.(bool); ok && !b {
-				break
+				condResult = false
 			}
 		}
 
+		if !condResult {
+			// Record the failing check so the loop exit is visible
+			e.addStepWithLoop(line, "for_cond", "condition check", loopID, iteration)
+			e.recordCondition(false)
+			break
+		}
+
 		iteration++
 		e.loopIterations[loopID] = iteration
 
 		// Add condition check step
 		e.addStepWithLoop(line, "for_cond", "condition check", loopID, iteration)
+		if s.Cond != nil {
+			e.recordCondition(true)
+		}
 
 		// Execute body
-		e.scopeStack = append(e.scopeStack, loopID)
+		// The body is a block of its own, so it can shadow the loop variables
 		if s.Body != nil {
+			e.pushScope("body")
 			e.executeBlock(s.Body.List)
+			e.popScope()
 		}
-		e.scopeStack = e.scopeStack[:len(e.scopeStack)-1]
-
-		if e.hasReturned || e.hasBroken {
-			e.hasBroken = false
+		if e.loopDone(label) {
 			break
 		}
 
-		if e.hasContinued {
-			e.hasContinued = false
-		}
+		// The next iteration gets fresh copies of the loop variables, which
+		// the post statement then updates
+		e.scope = e.scope.clone()
 
 		// Execute post
 		if s.Post != nil {
-			e.executeStmt(s.Post)
+			e.executePost(s.Post, loopID, iteration)
 		}
 	}
 }
 
+// executePost runs a for-loop post statement and records it as a for_post step
+func (e *simpleExecutor) executePost(s ast.Stmt, loopID string, iteration int) {
+	line := e.fset.Position(s.Pos()).Line
+
+	switch post := s.(type) {
+	case *ast.IncDecStmt:
+		e.applyIncDec(post)
+	case *ast.AssignStmt:
+		e.applyAssign(post)
+	default:
+		e.executeStmt(s)
+		return
+	}
+
+	e.addStepWithLoop(line, "for_post", e.getStatementText(s), loopID, iteration)
+}
+
 func (e *simpleExecutor) executeRange(s *ast.RangeStmt) {
 	line := e.fset.Position(s.Pos()).Line
+	label := e.takeLabel()
 	e.loopCounter++
 	loopID := fmt.Sprintf("range_%d", e.loopCounter)
 
 	collection := e.evalExpr(s.X)
 
-	// Get key/value variable names (handle _ underscore)
-	keyName := ""
-	valName := ""
-	if s.Key != nil {
-		if ident, ok := s.Key.(*ast.Ident); ok && ident.Name != "_" {
-			keyName = ident.Name
-		}
+	// Get key/value variables (handle _ underscore)
+	var keyVar, valVar *ast.Ident
+	if ident, ok := s.Key.(*ast.Ident); ok && ident.Name != "_" {
+		keyVar = ident
 	}
-	if s.Value != nil {
-		if ident, ok := s.Value.(*ast.Ident); ok && ident.Name != "_" {
-			valName = ident.Name
+	if ident, ok := s.Value.(*ast.Ident); ok && ident.Name != "_" {
+		valVar = ident
+	}
+	set := func(ident *ast.Ident, value interface{}) {
+		switch {
+		case ident == nil:
+		case s.Tok == token.DEFINE:
+			e.declare(ident, value)
+		default:
+			e.store(ident, value)
 		}
 	}
 
@@ -269,104 +629,98 @@ func (e *simpleExecutor) executeRange(s *ast.RangeStmt) {
 
 	maxIterations := 100
 	iteration := 0
+	stopped := false
+
+	// Helper to run one iteration: each declares its own key and value
+	// variables in the loop's block
+	runBody := func(key, value interface{}) {
+		e.pushScope(loopID)
+		defer e.popScope()
+		set(keyVar, key)
+		set(valVar, value)
 
-	// Helper to run one iteration body
-	runBody := func() {
 		iteration++
 		e.loopIterations[loopID] = iteration
 		e.addStepWithLoop(line, "for_cond", "range iteration", loopID, iteration)
 
-		e.scopeStack = append(e.scopeStack, loopID)
+		// The body is a block of its own, so it can shadow the loop variables
 		if s.Body != nil {
+			e.pushScope("body")
 			e.executeBlock(s.Body.List)
+			e.popScope()
 		}
-		e.scopeStack = e.scopeStack[:len(e.scopeStack)-1]
-
-		if e.hasContinued {
-			e.hasContinued = false
-		}
+		stopped = e.loopDone(label)
 	}
 
 	switch c := collection.(type) {
-	case []int:
-		for i, v := range c {
-			if e.hasReturned || e.hasBroken || iteration >= maxIterations {
-				break
-			}
-			if keyName != "" {
-				e.variables[keyName] = i
-				e.varTypes[keyName] = "int"
-			}
-			if valName != "" {
-				e.variables[valName] = v
-				e.varTypes[valName] = "int"
-			}
-			runBody()
-		}
-	case []string:
-		for i, v := range c {
-			if e.hasReturned || e.hasBroken || iteration >= maxIterations {
-				break
-			}
-			if keyName != "" {
-				e.variables[keyName] = i
-				e.varTypes[keyName] = "int"
-			}
-			if valName != "" {
-				e.variables[valName] = v
-				e.varTypes[valName] = "string"
-			}
-			runBody()
-		}
-	case []float64:
-		for i, v := range c {
-			if e.hasReturned || e.hasBroken || iteration >= maxIterations {
+	case string:
+		// Strings range over runes: the key is the byte offset of each one,
+		// and invalid UTF-8 decodes to U+FFFD one byte at a time
+		for i, r := range c {
+			if stopped || iteration >= maxIterations {
 				break
 			}
-			if keyName != "" {
-				e.variables[keyName] = i
-				e.varTypes[keyName] = "int"
-			}
-			if valName != "" {
-				e.variables[valName] = v
-				e.varTypes[valName] = "float64"
-			}
-			runBody()
+			runBody(i, r)
 		}
 	case map[interface{}]interface{}:
 		for k, v := range c {
-			if e.hasReturned || e.hasBroken || iteration >= maxIterations {
+			if stopped || iteration >= maxIterations {
 				break
 			}
-			if keyName != "" {
-				e.variables[keyName] = k
-				e.varTypes[keyName] = fmt.Sprintf("%T", k)
-			}
-			if valName != "" {
-				e.variables[valName] = v
-				e.varTypes[valName] = fmt.Sprintf("%T", v)
+			runBody(k, v)
+		}
+	default:
+		if slice := reflect.ValueOf(c); slice.Kind() == reflect.Slice || slice.Kind() == reflect.Array {
+			for i := 0; i < slice.Len(); i++ {
+				if stopped || iteration >= maxIterations {
+					break
+				}
+				runBody(i, slice.Index(i).Interface())
 			}
-			runBody()
 		}
 	}
 
-	e.hasBroken = false
+	if !stopped {
+		// Record the exhausted range so the loop exit is visible
+		e.addStepWithLoop(line, "for_cond", "range done", loopID, iteration)
+		e.recordCondition(false)
+	}
 }
 
 func (e *simpleExecutor) executeIf(s *ast.IfStmt) {
 	line := e.fset.Position(s.Pos()).Line
+	id := e.blockIDs[s]
 
-	e.addStep(line, "if_cond", "if condition")
+	// The init statement's variables are visible in every branch
+	e.pushScope(fmt.Sprintf("if_%d", id))
+	defer e.popScope()
+	if s.Init != nil {
+		e.executeStmt(s.Init)
+	}
 
 	condValue := e.evalExpr(s.Cond)
+	condResult := false
 	if b, ok := condValue.(bool); ok && b {
+		condResult = true
+	}
+
+	e.addStep(line, "if_cond", "if condition")
+	e.recordCondition(condResult)
+
+	if condResult {
 		if s.Body != nil {
+			e.pushScope("")
+			e.addStep(e.fset.Position(s.Body.Pos()).Line, "if_body", "if body")
 			e.executeBlock(s.Body.List)
+			e.popScope()
 		}
 	} else if s.Else != nil {
 		switch el := s.Else.(type) {
 		case *ast.BlockStmt:
+			e.pushScope(fmt.Sprintf("else_%d", id))
+			e.addStep(e.fset.Position(el.Pos()).Line, "else_body", "else")
 			e.executeBlock(el.List)
+			e.popScope()
 		case *ast.IfStmt:
 			e.executeIf(el)
 		}
@@ -378,28 +732,27 @@ func (e *simpleExecutor) executeExpr(s *ast.ExprStmt) {
 	var stepOutput string
 
 	if call, ok := s.X.(*ast.CallExpr); ok {
-		if ident, ok := call.Fun.(*ast.Ident); ok {
-			// Check for user-defined function call as statement
-			if _, isUserFunc := e.functions[ident.Name]; isUserFunc {
-				e.executeUserFunc(e.functions[ident.Name], call)
-				return
-			}
-			// Handle delete() as statement
-			if ident.Name == "delete" {
+		// Calls of the program's functions are traced as such
+		if f, ok := e.callee(call); ok {
+			e.callFunc(f, call)
+			return
+		}
+		if ident := calleeIdent(call); ident != nil {
+			// Builtins used as statements
+			switch ident.Name {
+			case "delete", "copy", "clear":
 				e.evalCallExpr(call)
 				e.addStep(line, "call", e.getStatementText(s))
 				return
 			}
+			e.unsupported(call, "call to "+ident.Name)
 		}
 
 		// Check for fmt.Print calls
 		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
 			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "fmt" {
 				// Evaluate and capture output
-				var args []interface{}
-				for _, arg := range call.Args {
-					args = append(args, e.evalExpr(arg))
-				}
+				args := e.evalArgs(call.Args)
 
 				switch sel.Sel.Name {
 				case "Println":
@@ -412,10 +765,14 @@ func (e *simpleExecutor) executeExpr(s *ast.ExprStmt) {
 							stepOutput = fmt.Sprintf(format, args[1:]...)
 						}
 					}
+				default:
+					e.unsupported(call, "call to fmt."+sel.Sel.Name)
 				}
 
 				// Add to total output
 				e.output.WriteString(stepOutput)
+			} else {
+				e.unsupported(call, "call to "+e.exprText(call.Fun))
 			}
 		}
 	}
@@ -425,49 +782,40 @@ func (e *simpleExecutor) executeExpr(s *ast.ExprStmt) {
 
 func (e *simpleExecutor) executeIncDec(s *ast.IncDecStmt) {
 	line := e.fset.Position(s.Pos()).Line
+	e.applyIncDec(s)
+	e.addStep(line, "assign", e.getStatementText(s))
+}
 
-	switch x := s.X.(type) {
-	case *ast.Ident:
-		// Simple: i++
-		if val, ok := e.variables[x.Name]; ok {
-			if intVal, ok := val.(int); ok {
-				if s.Tok == token.INC {
-					e.variables[x.Name] = intVal + 1
-				} else {
-					e.variables[x.Name] = intVal - 1
-				}
-			}
-		}
-	case *ast.IndexExpr:
-		// Map/slice index: counts[e]++
-		if ident, ok := x.X.(*ast.Ident); ok {
-			if m, ok := e.variables[ident.Name].(map[interface{}]interface{}); ok {
-				key := e.evalExpr(x.Index)
-				current := 0
-				if val, exists := m[key]; exists {
-					if intVal, ok := val.(int); ok {
-						current = intVal
-					}
-				}
-				if s.Tok == token.INC {
-					m[key] = current + 1
-				} else {
-					m[key] = current - 1
-				}
-				e.variables[ident.Name] = m
-			}
-		}
+func (e *simpleExecutor) applyIncDec(s *ast.IncDecStmt) {
+	// x++ is x += 1, for variables, map entries (counts[e]++) and slice
+	// elements alike
+	current := e.evalExpr(s.X)
+	op := token.ADD
+	if s.Tok == token.DEC {
+		op = token.SUB
 	}
-
-	e.addStep(line, "assign", e.getStatementText(s))
+	value := e.evalBinary(current, one(current), op)
+	if value == nil {
+		e.fail(s, "operator %s not supported on %s", s.Tok, typeName(current))
+	}
+	e.store(s.X, value)
 }
 
 func (e *simpleExecutor) executeReturn(s *ast.ReturnStmt) {
 	line := e.fset.Position(s.Pos()).Line
 
 	// Evaluate return value if present
-	if len(s.Results) > 0 {
+	switch {
+	case len(s.Results) > 1:
+		values := make(tuple, len(s.Results))
+		for i, result := range s.Results {
+			values[i] = e.evalExpr(result)
+		}
+		e.returnValue = values
+	case len(s.Results) == 1:
 		e.returnValue = e.evalExpr(s.Results[0])
+	case e.results != nil:
+		e.returnValue = e.namedResults()
 	}
 	e.hasReturned = true
 
@@ -476,8 +824,11 @@ func (e *simpleExecutor) executeReturn(s *ast.ReturnStmt) {
 
 func (e *simpleExecutor) executeSwitch(s *ast.SwitchStmt) {
 	line := e.fset.Position(s.Pos()).Line
+	label := e.takeLabel()
 
 	// Execute init statement if present (e.g., switch x := val; x { ... })
+	e.pushScope(fmt.Sprintf("switch_%d", e.blockIDs[s]))
+	defer e.popScope()
 	if s.Init != nil {
 		e.executeStmt(s.Init)
 	}
@@ -496,78 +847,98 @@ func (e *simpleExecutor) executeSwitch(s *ast.SwitchStmt) {
 	}
 	e.addStep(line, "switch_tag", switchLabel)
 
-	// Iterate case clauses
-	matched := false
+	var clauses []*ast.CaseClause
 	if s.Body != nil {
 		for _, stmt := range s.Body.List {
-			cc, ok := stmt.(*ast.CaseClause)
-			if !ok {
-				continue
+			if cc, ok := stmt.(*ast.CaseClause); ok {
+				clauses = append(clauses, cc)
 			}
+		}
+	}
 
-			caseLine := e.fset.Position(cc.Pos()).Line
+	// Cases are tried top to bottom; default runs when none matches,
+	// wherever it is written
+	matched := -1
+	def := -1
+	for i, cc := range clauses {
+		if cc.List == nil {
+			def = i
+			continue
+		}
+		if e.caseMatches(s, tagValue, cc) {
+			matched = i
+			break
+		}
+	}
+	if matched < 0 {
+		matched = def
+	}
 
-			if cc.List == nil {
-				// default case — only run if nothing matched yet
-				if !matched {
-					e.addStep(caseLine, "case_match", "default")
-					e.executeBlock(cc.Body)
-					matched = true
-				}
-				continue
-			}
+	// fallthrough enters the next clause without checking it
+	entered := true
+	for i := matched; i >= 0 && i < len(clauses); i++ {
+		e.executeCase(clauses[i], entered)
+		if !e.hasFallthrough {
+			break
+		}
+		e.hasFallthrough = false
+		entered = false
+	}
 
-			// Check if any case expression matches the tag
-			caseMatched := false
-			for _, expr := range cc.List {
-				caseVal := e.evalExpr(expr)
-				if s.Tag != nil {
-					// Expression switch: compare tag to case values
-					if tagValue == caseVal {
-						caseMatched = true
-						break
-					}
-				} else {
-					// Bool switch (no tag): case expressions should be bool
-					if b, ok := caseVal.(bool); ok && b {
-						caseMatched = true
-						break
-					}
-				}
-			}
+	// An unlabeled break in a case leaves the switch, not the enclosing loop
+	if e.hasBroken && e.targets(label) {
+		e.hasBroken = false
+		e.branchLabel = ""
+	}
+}
 
-			if caseMatched && !matched {
-				// Build case label from expressions
-				var caseLabel string
-				var parts []string
-				for _, expr := range cc.List {
-					var buf bytes.Buffer
-					printer.Fprint(&buf, e.fset, expr)
-					parts = append(parts, buf.String())
-				}
-				caseLabel = "case " + strings.Join(parts, ", ")
-				e.addStep(caseLine, "case_match", caseLabel)
-				e.executeBlock(cc.Body)
-				matched = true
+// caseMatches evaluates the expressions of a case clause until one matches
+func (e *simpleExecutor) caseMatches(s *ast.SwitchStmt, tagValue interface{}, cc *ast.CaseClause) bool {
+	for _, expr := range cc.List {
+		caseVal := e.evalExpr(expr)
+		if s.Tag != nil {
+			// Expression switch: compare tag to case values
+			if tagValue == caseVal {
+				return true
 			}
+		} else if b, ok := caseVal.(bool); ok && b {
+			// Bool switch (no tag): case expressions should be bool
+			return true
 		}
 	}
+	return false
 }
 
-func (e *simpleExecutor) executeBranch(s *ast.BranchStmt) {
-	line := e.fset.Position(s.Pos()).Line
+// executeCase runs the body of a case clause, which is a block of its own.
+// matched is false when a fallthrough entered it.
+func (e *simpleExecutor) executeCase(cc *ast.CaseClause, matched bool) {
+	label := "default"
+	if cc.List != nil {
+		parts := make([]string, len(cc.List))
+		for i, expr := range cc.List {
+			parts[i] = e.exprText(expr)
+		}
+		label = "case " + strings.Join(parts, ", ")
+	}
 
-	switch s.Tok {
-	case token.BREAK:
-		e.hasBroken = true
-		e.addStep(line, "break", "break")
-	case token.CONTINUE:
-		e.hasContinued = true
-		e.addStep(line, "continue", "continue")
+	e.pushScope("case")
+	defer e.popScope()
+	e.addStep(e.fset.Position(cc.Pos()).Line, "case_match", label)
+	if matched {
+		e.recordCondition(true)
 	}
+	e.executeBlock(cc.Body)
 }
 
 func (e *simpleExecutor) evalExpr(expr ast.Expr) interface{} {
+	// Constant expressions come out of the type checker already folded and
+	// converted to the type their context gives them
+	if tv, ok := e.info.Types[expr]; ok && tv.Value != nil {
+		if v := constValue(tv.Value, e.concrete(tv.Type)); v != nil {
+			return v
+		}
+	}
+
 	switch ex := expr.(type) {
 	case *ast.BasicLit:
 		switch ex.Kind {
@@ -576,15 +947,18 @@ func (e *simpleExecutor) evalExpr(expr ast.Expr) interface{} {
 			fmt.Sscanf(ex.Value, "%d", &val)
 			return val
 		case token.STRING:
-			// Remove quotes
-			return strings.Trim(ex.Value, `"`)
+			val, _ := strconv.Unquote(ex.Value)
+			return val
+		case token.CHAR:
+			val, _, _, _ := strconv.UnquoteChar(ex.Value[1:len(ex.Value)-1], '\'')
+			return val
 		case token.FLOAT:
 			var val float64
 			fmt.Sscanf(ex.Value, "%f", &val)
 			return val
 		}
 	case *ast.Ident:
-		if val, ok := e.variables[ex.Name]; ok {
+		if val, ok := e.lookupVar(ex); ok {
 			return val
 		}
 		if ex.Name == "true" {
@@ -593,78 +967,130 @@ func (e *simpleExecutor) evalExpr(expr ast.Expr) interface{} {
 		if ex.Name == "false" {
 			return false
 		}
+		if ex.Name == "nil" {
+			return nil
+		}
+		if f, ok := e.funcRef(ex); ok {
+			return f
+		}
 		return 0
 	case *ast.BinaryExpr:
 		left := e.evalExpr(ex.X)
+		// && and || only evaluate the right operand when they need it
+		if b, ok := left.(bool); ok && (ex.Op == token.LAND && !b || ex.Op == token.LOR && b) {
+			return b
+		}
 		right := e.evalExpr(ex.Y)
-		return e.evalBinary(left, right, ex.Op)
+		result := e.evalBinary(left, right, ex.Op)
+		if result == nil {
+			e.fail(ex, "operator %s not supported on %s and %s", ex.Op, typeName(left), typeName(right))
+		}
+		return result
+	case *ast.UnaryExpr:
+		switch ex.Op {
+		case token.AND:
+			return e.evalAddress(ex)
+		case token.ARROW:
+			e.fail(ex, "cannot receive from %s: channels are not supported yet", e.exprText(ex.X))
+		}
+		operand := e.evalExpr(ex.X)
+		result := evalUnary(ex.Op, operand)
+		if result == nil {
+			e.fail(ex, "operator %s not supported on %s", ex.Op, typeName(operand))
+		}
+		return result
 	case *ast.ParenExpr:
 		return e.evalExpr(ex.X)
 	case *ast.CompositeLit:
 		// Handle slice/array literals like []int{5, 2, 8, 1, 9}
 		return e.evalCompositeLit(ex)
 	case *ast.IndexExpr:
+		if f, ok := e.funcRef(ex); ok {
+			// An instantiation, as Max[int]
+			return f
+		}
 		// Handle slice/array indexing like arr[i]
 		return e.evalIndexExpr(ex)
+	case *ast.IndexListExpr:
+		if f, ok := e.funcRef(ex); ok {
+			return f
+		}
+	case *ast.SelectorExpr:
+		return e.evalSelector(ex)
+	case *ast.StarExpr:
+		if addr, ok := e.addressOf(ex); ok {
+			return addr.Interface()
+		}
+	case *ast.FuncLit:
+		return e.closure(ex)
+	case *ast.SliceExpr:
+		return e.evalSliceExpr(ex)
 	case *ast.CallExpr:
 		// Handle built-in functions like len()
 		return e.evalCallExpr(ex)
 	}
+	if lit, ok := expr.(*ast.BasicLit); ok {
+		e.unsupported(expr, lit.Kind.String()+" literal")
+	} else {
+		e.unsupported(expr, "expression "+nodeKind(expr))
+	}
 	return nil
 }
 
 func (e *simpleExecutor) evalCompositeLit(lit *ast.CompositeLit) interface{} {
-	// Check if it's a slice type
-	if arrayType, ok := lit.Type.(*ast.ArrayType); ok {
-		if ident, ok := arrayType.Elt.(*ast.Ident); ok {
-			switch ident.Name {
-			case "int":
-				result := make([]int, 0, len(lit.Elts))
-				for _, elt := range lit.Elts {
-					val := e.evalExpr(elt)
-					if intVal, ok := val.(int); ok {
-						result = append(result, intVal)
-					}
-				}
-				return result
-			case "string":
-				result := make([]string, 0, len(lit.Elts))
-				for _, elt := range lit.Elts {
-					val := e.evalExpr(elt)
-					if strVal, ok := val.(string); ok {
-						result = append(result, strVal)
-					}
-				}
-				return result
-			case "float64":
-				result := make([]float64, 0, len(lit.Elts))
-				for _, elt := range lit.Elts {
-					val := e.evalExpr(elt)
-					if floatVal, ok := val.(float64); ok {
-						result = append(result, floatVal)
-					}
-				}
-				return result
-			}
-		}
-	}
-
-	// Check if it's a map type: map[K]V{...}
-	if _, ok := lit.Type.(*ast.MapType); ok {
-		result := make(map[interface{}]interface{})
+	// Arrays, slices and maps of any type the interpreter models, including
+	// nested ones whose inner literals leave out the type
+	switch rt := reflectType(e.typeOf(lit)); {
+	case rt == nil:
+		// Such as a struct referring to itself
+	case rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array:
+		return e.evalListLit(lit, rt)
+	case rt.Kind() == reflect.Struct:
+		return e.evalStructLit(lit, rt)
+	case rt == mapType:
+		result := make(map[interface{}]interface{}, len(lit.Elts))
 		for _, elt := range lit.Elts {
 			if kv, ok := elt.(*ast.KeyValueExpr); ok {
 				key := e.evalExpr(kv.Key)
-				val := e.evalExpr(kv.Value)
-				result[key] = val
+				result[key] = e.evalExpr(kv.Value)
 			}
 		}
 		return result
 	}
 
+	e.unsupported(lit, "composite literal of type "+typeString(e.typeOf(lit)))
 	return nil
 }
 
+// evalListLit builds an array or slice literal. An element may give its
+// index, as in [5]int{2: 1}; the others follow the element before them.
+func (e *simpleExecutor) evalListLit(lit *ast.CompositeLit, rt reflect.Type) interface{} {
+	elems := make(map[int]reflect.Value, len(lit.Elts))
+	n, i := 0, 0
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			i = intIndex(e.evalExpr(kv.Key))
+			elt = kv.Value
+		}
+		elems[i] = e.assignable(elt, e.evalExpr(elt), rt.Elem())
+		i++
+		if i > n {
+			n = i
+		}
+	}
+
+	var result reflect.Value
+	if rt.Kind() == reflect.Array {
+		result = reflect.New(rt).Elem()
+	} else {
+		result = reflect.MakeSlice(rt, n, n)
+	}
+	for i, v := range elems {
+		result.Index(i).Set(v)
+	}
+	return result.Interface()
+}
+
 func (e *simpleExecutor) evalIndexExpr(idx *ast.IndexExpr) interface{} {
 	collection := e.evalExpr(idx.X)
 	index := e.evalExpr(idx.Index)
@@ -674,88 +1100,163 @@ func (e *simpleExecutor) evalIndexExpr(idx *ast.IndexExpr) interface{} {
 		if val, exists := m[index]; exists {
 			return val
 		}
-		return 0 // zero value for missing map keys
+		// Missing keys give the zero value of the element type
+		if zero := zeroOf(e.typeOf(idx)); zero != nil {
+			return zero
+		}
+		return 0
+	}
+
+	// Indexing a string gives its bytes, not its characters
+	if s, ok := collection.(string); ok {
+		return s[e.checkIndex(index, len(s))]
 	}
 
 	// Slice/array index
-	if indexInt, ok := index.(int); ok {
-		switch a := collection.(type) {
-		case []int:
-			if indexInt >= 0 && indexInt < len(a) {
-				return a[indexInt]
-			}
-		case []string:
-			if indexInt >= 0 && indexInt < len(a) {
-				return a[indexInt]
-			}
-		case []float64:
-			if indexInt >= 0 && indexInt < len(a) {
-				return a[indexInt]
-			}
-		}
+	if slice := reflect.ValueOf(collection); slice.Kind() == reflect.Slice || slice.Kind() == reflect.Array {
+		return slice.Index(e.checkIndex(index, slice.Len())).Interface()
 	}
 	return nil
 }
 
+func (e *simpleExecutor) evalSliceExpr(ex *ast.SliceExpr) interface{} {
+	collection := e.evalExpr(ex.X)
+	var low, high, max interface{}
+	if ex.Low != nil {
+		low = e.evalExpr(ex.Low)
+	}
+	if ex.High != nil {
+		high = e.evalExpr(ex.High)
+	}
+	if ex.Max != nil {
+		max = e.evalExpr(ex.Max)
+	}
+
+	// Like indexing, slicing a string counts bytes
+	if s, ok := collection.(string); ok {
+		lo, hi, _ := e.checkSlice(low, high, nil, len(s), len(s), "length")
+		return s[lo:hi]
+	}
+
+	// The result is a view of the same array: a slice of a slice shares its
+	// elements, and a slice of an array variable shares the variable's
+	v := reflect.ValueOf(collection)
+	limit := "capacity"
+	switch v.Kind() {
+	case reflect.Slice:
+	case reflect.Array:
+		addr, ok := e.addressOf(ex.X)
+		if !ok {
+			addr = reflect.New(v.Type()).Elem()
+			addr.Set(v)
+		}
+		v = addr
+		limit = "length"
+	default:
+		e.unsupported(ex, "slice expression on "+typeName(collection))
+		return nil
+	}
+	lo, hi, mx := e.checkSlice(low, high, max, v.Len(), v.Cap(), limit)
+	return v.Slice3(lo, hi, mx).Interface()
+}
+
 func (e *simpleExecutor) evalCallExpr(call *ast.CallExpr) interface{} {
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		// Check user-defined functions first
-		if fn, ok := e.functions[ident.Name]; ok {
-			return e.executeUserFunc(fn, call)
+	// Conversion: T(x)
+	if tv, ok := e.info.Types[call.Fun]; ok && tv.IsType() && len(call.Args) == 1 {
+		arg := e.evalExpr(call.Args[0])
+		to := e.concrete(tv.Type)
+		result, ok := convert(arg, to)
+		if !ok {
+			e.fail(call, "cannot convert %s to %s", typeName(arg), typeString(to))
 		}
+		return result
+	}
+
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "cmp" && len(call.Args) == 2 {
+			switch sel.Sel.Name {
+			case "Compare":
+				return e.compare(e.evalExpr(call.Args[0]), e.evalExpr(call.Args[1]))
+			case "Less":
+				return e.compare(e.evalExpr(call.Args[0]), e.evalExpr(call.Args[1])) < 0
+			}
+		}
+	}
+
+	// The program's functions and methods, and function values
+	if f, ok := e.callee(call); ok {
+		return e.callFunc(f, call)
+	}
+
+	if ident, ok := call.Fun.(*ast.Ident); ok {
 
 		// Handle built-in functions
 		switch ident.Name {
 		case "len":
 			if len(call.Args) > 0 {
-				arg := e.evalExpr(call.Args[0])
-				switch a := arg.(type) {
-				case []int:
-					return len(a)
-				case []string:
-					return len(a)
-				case []float64:
-					return len(a)
-				case string:
-					return len(a)
-				case map[interface{}]interface{}:
-					return len(a)
+				arg := reflect.ValueOf(e.evalExpr(call.Args[0]))
+				switch arg.Kind() {
+				case reflect.Invalid:
+					// nil slice or map
+					return 0
+				case reflect.Slice, reflect.Array, reflect.String, reflect.Map:
+					return arg.Len()
 				}
 			}
 		case "make":
-			// make(map[K]V) or make([]T, len)
 			if len(call.Args) > 0 {
-				if _, ok := call.Args[0].(*ast.MapType); ok {
-					return make(map[interface{}]interface{})
+				return e.evalMake(call)
+			}
+		case "cap":
+			if len(call.Args) == 1 {
+				arg := reflect.ValueOf(e.evalExpr(call.Args[0]))
+				switch arg.Kind() {
+				case reflect.Invalid:
+					return 0
+				case reflect.Slice, reflect.Array:
+					return arg.Cap()
 				}
 			}
+		case "copy":
+			if len(call.Args) == 2 {
+				return e.evalCopy(call)
+			}
+		case "min", "max":
+			if len(call.Args) > 0 {
+				return e.evalMinMax(call, ident.Name == "min")
+			}
+		case "clear":
+			if len(call.Args) == 1 {
+				e.evalClear(call)
+			}
+			return nil
 		case "append":
-			if len(call.Args) >= 2 {
-				sliceArg := e.evalExpr(call.Args[0])
-				valArg := e.evalExpr(call.Args[1])
-				switch s := sliceArg.(type) {
-				case []int:
-					if v, ok := valArg.(int); ok {
-						return append(s, v)
-					}
-				case []string:
-					if v, ok := valArg.(string); ok {
-						return append(s, v)
-					}
-				case []float64:
-					if v, ok := valArg.(float64); ok {
-						return append(s, v)
+			if len(call.Args) > 0 {
+				return e.evalAppend(call)
+			}
+		case "complex":
+			if len(call.Args) == 2 {
+				re, im := reflect.ValueOf(e.evalExpr(call.Args[0])), reflect.ValueOf(e.evalExpr(call.Args[1]))
+				if re.Kind() == reflect.Float32 && im.Kind() == reflect.Float32 {
+					return complex(float32(re.Float()), float32(im.Float()))
+				}
+				if re.Kind() == reflect.Float64 && im.Kind() == reflect.Float64 {
+					return complex(re.Float(), im.Float())
+				}
+			}
+		case "real", "imag":
+			if len(call.Args) == 1 {
+				switch c := e.evalExpr(call.Args[0]).(type) {
+				case complex64:
+					if ident.Name == "real" {
+						return real(c)
 					}
-				case nil:
-					// var simpan []int => nil, then append
-					switch v := valArg.(type) {
-					case int:
-						return []int{v}
-					case string:
-						return []string{v}
-					case float64:
-						return []float64{v}
+					return imag(c)
+				case complex128:
+					if ident.Name == "real" {
+						return real(c)
 					}
+					return imag(c)
 				}
 			}
 		case "delete":
@@ -769,96 +1270,173 @@ func (e *simpleExecutor) evalCallExpr(call *ast.CallExpr) interface{} {
 			return nil
 		}
 	}
+	e.unsupported(call, "call to "+e.exprText(call.Fun))
 	return nil
 }
 
-func (e *simpleExecutor) executeUserFunc(fn *ast.FuncDecl, call *ast.CallExpr) interface{} {
-	// Safety: check call depth
-	if len(e.callStack) >= e.maxCallDepth {
-		line := e.fset.Position(call.Pos()).Line
-		e.addStep(line, "func_call", fn.Name.Name+"(...) — max call depth reached")
-		return nil
+// evalAppend implements append(s, x...) and append(s, t...)
+func (e *simpleExecutor) evalAppend(call *ast.CallExpr) interface{} {
+	first := e.evalExpr(call.Args[0])
+	slice := reflect.ValueOf(first)
+	if !slice.IsValid() {
+		// Appending to a nil slice
+		if rt := reflectType(e.typeOf(call)); rt != nil {
+			slice = reflect.Zero(rt)
+		}
 	}
-
-	// Evaluate arguments in caller scope
-	args := make([]interface{}, len(call.Args))
-	for i, arg := range call.Args {
-		args[i] = e.evalExpr(arg)
+	if slice.Kind() != reflect.Slice {
+		e.fail(call, "cannot append to %s", typeName(first))
 	}
 
-	// Record func_call step (in caller context)
-	line := e.fset.Position(call.Pos()).Line
-	e.addStep(line, "func_call", fn.Name.Name+"(...)")
-
-	// Save caller state (deep copy to prevent corruption during recursion)
-	savedVars := make(map[string]interface{}, len(e.variables))
-	for k, v := range e.variables {
-		savedVars[k] = v
+	if call.Ellipsis.IsValid() && len(call.Args) == 2 {
+		more := reflect.ValueOf(e.evalExpr(call.Args[1]))
+		if !more.IsValid() {
+			return slice.Interface()
+		}
+		if more.Kind() == reflect.String {
+			// append(b, s...) appends the bytes of s
+			more = reflect.ValueOf([]byte(more.String()))
+		}
+		if more.Type() != slice.Type() {
+			e.fail(call, "cannot append %s to %s", more.Type(), slice.Type())
+		}
+		return reflect.AppendSlice(slice, more).Interface()
 	}
-	savedTypes := make(map[string]string, len(e.varTypes))
-	for k, v := range e.varTypes {
-		savedTypes[k] = v
+	// The values are appended at once: when they don't all fit, none is
+	// written into the old array
+	more := reflect.MakeSlice(slice.Type(), 0, len(call.Args)-1)
+	for _, arg := range call.Args[1:] {
+		more = reflect.Append(more, e.assignable(arg, e.evalExpr(arg), slice.Type().Elem()))
 	}
-	savedScope := make([]string, len(e.scopeStack))
-	copy(savedScope, e.scopeStack)
+	return reflect.AppendSlice(slice, more).Interface()
+}
 
-	frame := CallFrame{
-		FuncName:       fn.Name.Name,
-		SavedVars:      savedVars,
-		SavedTypes:     savedTypes,
-		SavedScope:     savedScope,
-		SavedReturned:  e.hasReturned,
-		SavedReturnVal: e.returnValue,
+// evalMake implements make(map[K]V) and make([]T, len, cap)
+func (e *simpleExecutor) evalMake(call *ast.CallExpr) interface{} {
+	rt := reflectType(e.typeOf(call))
+	if rt == nil {
+		e.unsupported(call, "make of "+e.exprText(call.Args[0]))
+		return nil
+	}
+	if rt.Kind() == reflect.Map {
+		return make(map[interface{}]interface{})
+	}
+	if rt.Kind() != reflect.Slice || len(call.Args) < 2 {
+		e.unsupported(call, "make of "+e.exprText(call.Args[0]))
+		return nil
 	}
 
-	// Push call frame
-	e.callStack = append(e.callStack, frame)
+	n := intIndex(e.evalExpr(call.Args[1]))
+	if n < 0 {
+		runtimePanic("makeslice: len out of range")
+	}
+	c := n
+	if len(call.Args) == 3 {
+		c = intIndex(e.evalExpr(call.Args[2]))
+		if c < n {
+			runtimePanic("makeslice: cap out of range")
+		}
+	}
+	return reflect.MakeSlice(rt, n, c).Interface()
+}
 
-	// Create fresh scope for callee
-	e.variables = make(map[string]interface{})
-	e.varTypes = make(map[string]string)
-	e.scopeStack = []string{fn.Name.Name}
+// evalCopy implements copy(dst, src), which may copy the bytes of a string
+func (e *simpleExecutor) evalCopy(call *ast.CallExpr) interface{} {
+	dst := reflect.ValueOf(e.evalExpr(call.Args[0]))
+	src := reflect.ValueOf(e.evalExpr(call.Args[1]))
+	if !dst.IsValid() || !src.IsValid() {
+		// Copying to or from a nil slice copies nothing
+		return 0
+	}
+	if src.Kind() == reflect.String {
+		src = reflect.ValueOf([]byte(src.String()))
+	}
+	if dst.Kind() != reflect.Slice || src.Type() != dst.Type() {
+		e.fail(call, "cannot copy %s to %s", src.Type(), dst.Type())
+	}
+	return reflect.Copy(dst, src)
+}
 
-	// Bind parameters
-	if fn.Type.Params != nil {
-		argIdx := 0
-		for _, field := range fn.Type.Params.List {
-			typeName := e.getTypeString(field.Type)
-			for _, name := range field.Names {
-				if argIdx < len(args) {
-					e.variables[name.Name] = args[argIdx]
-					e.varTypes[name.Name] = typeName
-					argIdx++
-				}
-			}
+// evalMinMax implements min and max. As in Go, a NaN argument makes the
+// result NaN.
+func (e *simpleExecutor) evalMinMax(call *ast.CallExpr, min bool) interface{} {
+	op := token.GTR
+	if min {
+		op = token.LSS
+	}
+	result := e.evalExpr(call.Args[0])
+	for _, arg := range call.Args[1:] {
+		v := e.evalExpr(arg)
+		if isNaN(result) {
+			continue
+		}
+		if isNaN(v) || e.evalBinary(v, result, op) == true {
+			result = v
 		}
 	}
+	return result
+}
 
-	// Record func_enter step (in callee context)
-	if fn.Body != nil {
-		enterLine := e.fset.Position(fn.Body.Pos()).Line
-		e.addStep(enterLine, "func_enter", "enter "+fn.Name.Name)
+func isNaN(v interface{}) bool {
+	switch f := v.(type) {
+	case float32:
+		return f != f
+	case float64:
+		return f != f
 	}
+	return false
+}
 
-	// Execute function body
-	e.hasReturned = false
-	e.returnValue = nil
-	if fn.Body != nil {
-		e.executeBlock(fn.Body.List)
+// compare implements cmp.Compare, which orders NaN before the other floats
+// and equal to itself
+func (e *simpleExecutor) compare(x, y interface{}) int {
+	switch {
+	case isNaN(x) && isNaN(y):
+		return 0
+	case isNaN(x):
+		return -1
+	case isNaN(y):
+		return 1
+	case e.evalBinary(x, y, token.LSS) == true:
+		return -1
+	case e.evalBinary(x, y, token.GTR) == true:
+		return 1
 	}
+	return 0
+}
 
-	// Capture return value
-	result := e.returnValue
-
-	// Pop call frame: restore caller state
-	e.callStack = e.callStack[:len(e.callStack)-1]
-	e.variables = frame.SavedVars
-	e.varTypes = frame.SavedTypes
-	e.scopeStack = frame.SavedScope
-	e.hasReturned = frame.SavedReturned
-	e.returnValue = frame.SavedReturnVal
+// evalClear implements clear, which empties a map and zeroes the elements
+// of a slice
+func (e *simpleExecutor) evalClear(call *ast.CallExpr) {
+	switch v := e.evalExpr(call.Args[0]).(type) {
+	case nil:
+	case map[interface{}]interface{}:
+		for k := range v {
+			delete(v, k)
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			e.fail(call, "cannot clear %s", typeName(v))
+		}
+		zero := reflect.Zero(rv.Type().Elem())
+		for i := 0; i < rv.Len(); i++ {
+			rv.Index(i).Set(zero)
+		}
+	}
+}
 
-	return result
+// assignable returns value as a reflect.Value that can be stored in a slot
+// of type rt, failing the run when it can't
+func (e *simpleExecutor) assignable(node ast.Expr, value interface{}, rt reflect.Type) reflect.Value {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return reflect.Zero(rt)
+	}
+	if !v.Type().AssignableTo(rt) {
+		e.fail(node, "cannot use %s value as %s", typeName(value), rt)
+	}
+	return v
 }
 
 func (e *simpleExecutor) zeroValue(typeName string) interface{} {
@@ -883,6 +1461,9 @@ func (e *simpleExecutor) getTypeString(expr ast.Expr) string {
 	case *ast.Ident:
 		return t.Name
 	case *ast.ArrayType:
+		if t.Len != nil {
+			return "[" + e.exprText(t.Len) + "]" + e.getTypeString(t.Elt)
+		}
 		return "[]" + e.getTypeString(t.Elt)
 	case *ast.MapType:
 		return "map[" + e.getTypeString(t.Key) + "]" + e.getTypeString(t.Value)
@@ -891,58 +1472,6 @@ func (e *simpleExecutor) getTypeString(expr ast.Expr) string {
 	}
 }
 
-func (e *simpleExecutor) evalBinary(left, right interface{}, op token.Token) interface{} {
-	// Handle int operations
-	if l, ok := left.(int); ok {
-		if r, ok := right.(int); ok {
-			switch op {
-			case token.ADD:
-				return l + r
-			case token.SUB:
-				return l - r
-			case token.MUL:
-				return l * r
-			case token.QUO:
-				if r != 0 {
-					return l / r
-				}
-				return 0
-			case token.REM:
-				if r != 0 {
-					return l % r
-				}
-				return 0
-			case token.LSS:
-				return l < r
-			case token.LEQ:
-				return l <= r
-			case token.GTR:
-				return l > r
-			case token.GEQ:
-				return l >= r
-			case token.EQL:
-				return l == r
-			case token.NEQ:
-				return l != r
-			}
-		}
-	}
-
-	// Handle bool operations
-	if l, ok := left.(bool); ok {
-		if r, ok := right.(bool); ok {
-			switch op {
-			case token.LAND:
-				return l && r
-			case token.LOR:
-				return l || r
-			}
-		}
-	}
-
-	return nil
-}
-
 func (e *simpleExecutor) addStep(line int, stmtType, statement string) {
 	e.addStepWithOutput(line, stmtType, statement, "")
 }
@@ -969,7 +1498,7 @@ func (e *simpleExecutor) addStepWithOutput(line int, stmtType, statement, output
 		Statement:     statement,
 		StatementType: stmtType,
 		Variables:     e.captureVariables(),
-		ScopeStack:    append([]string{}, e.scopeStack...),
+		ScopeStack:    e.scopeStack(),
 		Output:        output,
 		CallStack:     e.captureCallStack(),
 		FunctionName:  e.currentFuncName(),
@@ -985,7 +1514,7 @@ func (e *simpleExecutor) addStepWithLoop(line int, stmtType, statement, loopID s
 		Statement:     statement,
 		StatementType: stmtType,
 		Variables:     e.captureVariables(),
-		ScopeStack:    append([]string{}, e.scopeStack...),
+		ScopeStack:    e.scopeStack(),
 		LoopIteration: &tracer.LoopIteration{
 			LoopID:    loopID,
 			Iteration: iteration,
@@ -997,34 +1526,113 @@ func (e *simpleExecutor) addStepWithLoop(line int, stmtType, statement, loopID s
 	e.stepIndex++
 }
 
-func (e *simpleExecutor) captureVariables() []tracer.Variable {
-	vars := make([]tracer.Variable, 0, len(e.variables))
-	scope := strings.Join(e.scopeStack, ".")
+// recordCondition attaches an evaluated branch condition to the most recent step
+func (e *simpleExecutor) recordCondition(result bool) {
+	if len(e.steps) > 0 {
+		e.steps[len(e.steps)-1].ConditionResult = &result
+	}
+}
 
-	for name, value := range e.variables {
-		vars = append(vars, tracer.Variable{
-			Name:  name,
-			Type:  cleanTypeName(e.varTypes[name]),
-			Value: toJSONSafe(value),
-			Scope: scope,
-		})
+// captureVariables returns the variables visible at this point, outermost
+// block first. A shadowed variable is hidden by the one shadowing it, whose
+// Scope names the inner block.
+func (e *simpleExecutor) captureVariables() []tracer.Variable {
+	var blocks []*scope
+	for s := e.scope; s != nil; s = s.parent {
+		blocks = append([]*scope{s}, blocks...)
 	}
 
+	vars := make([]tracer.Variable, 0)
+	var storage []memRange
+	for _, block := range blocks {
+		for _, name := range block.order {
+			if e.scope.lookup(name) != block {
+				continue
+			}
+			value := block.get(name)
+			typ := cleanTypeName(block.types[name])
+			v := tracer.Variable{
+				Name:    name,
+				Type:    typ,
+				Value:   toJSONSafe(value),
+				Display: display(typ, value),
+				Scope:   block.name,
+			}
+			if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice {
+				n, c := rv.Len(), rv.Cap()
+				v.Len, v.Cap = &n, &c
+			}
+			if r, ok := storageOf(block.vars[name]); ok {
+				r.variable = len(vars)
+				storage = append(storage, r)
+			}
+			vars = append(vars, v)
+		}
+	}
+	markShared(vars, storage)
 	return vars
 }
 
-// toJSONSafe converts values that can't be JSON-marshaled (e.g. map[interface{}]interface{})
-// into JSON-safe equivalents (map[string]interface{})
-func toJSONSafe(v interface{}) interface{} {
-	switch val := v.(type) {
-	case map[interface{}]interface{}:
-		safe := make(map[string]interface{}, len(val))
-		for k, v := range val {
-			safe[fmt.Sprintf("%v", k)] = toJSONSafe(v)
-		}
-		return safe
+// memRange is the memory a slice's backing array, or an array variable,
+// occupies
+type memRange struct {
+	start, end uintptr
+	variable   int
+}
+
+// storageOf returns the memory behind a slice or an array variable's cell
+func storageOf(v interface{}) (memRange, bool) {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+	var start uintptr
+	switch {
+	case rv.Kind() == reflect.Slice && rv.Cap() > 0:
+		start = rv.Pointer()
+	case rv.Kind() == reflect.Array && rv.CanAddr() && rv.Len() > 0:
+		start = rv.UnsafeAddr()
 	default:
-		return v
+		return memRange{}, false
+	}
+	size := uintptr(rv.Cap()) * rv.Type().Elem().Size()
+	if size == 0 {
+		return memRange{}, false
+	}
+	return memRange{start: start, end: start + size}, true
+}
+
+// markShared sets Backing on the variables whose memory overlaps another's,
+// so slices of the same array, and an array and its slices, get the same
+// "#N" label. Slicing a slice shares its array until append outgrows the
+// capacity and copies it.
+func markShared(vars []tracer.Variable, storage []memRange) {
+	sort.Slice(storage, func(i, j int) bool { return storage[i].start < storage[j].start })
+	var groups [][]int
+	var end uintptr
+	for i, r := range storage {
+		if i == 0 || r.start >= end {
+			groups = append(groups, nil)
+			end = r.end
+		} else if r.end > end {
+			end = r.end
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], r.variable)
+	}
+
+	// Number the groups in the order their variables are listed
+	var shared [][]int
+	for _, g := range groups {
+		if len(g) > 1 {
+			sort.Ints(g)
+			shared = append(shared, g)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool { return shared[i][0] < shared[j][0] })
+	for n, g := range shared {
+		for _, i := range g {
+			vars[i].Backing = fmt.Sprintf("#%d", n+1)
+		}
 	}
 }
 
@@ -1041,3 +1649,9 @@ func (e *simpleExecutor) getStatementText(stmt ast.Stmt) string {
 	printer.Fprint(&buf, e.fset, stmt)
 	return buf.String()
 }
+
+func (e *simpleExecutor) exprText(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, e.fset, expr)
+	return buf.String()
+}
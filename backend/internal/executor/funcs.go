@@ -0,0 +1,404 @@
+package executor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+)
+
+// funcValue is a function value: a declared function or method, or a
+// function literal together with the block it was evaluated in, whose
+// variables it shares with that block
+type funcValue struct {
+	// name names the function's block, as Max, Stack.Push or main.func1
+	name string
+	// instance is its name on the call stack, which adds the type arguments
+	// of a generic function or type, as Max[int] or Stack[int].Push
+	instance string
+	typ      *ast.FuncType
+	body     *ast.BlockStmt
+	// env is the block a function literal was evaluated in, nil for a
+	// declared function
+	env *scope
+	// typeArgs are those of a generic function or a generic type's method,
+	// or those in effect where a function literal was evaluated
+	typeArgs map[*types.TypeParam]types.Type
+	// recv is the receiver of a method, and self the value bound to it
+	recv *ast.FieldList
+	self interface{}
+}
+
+// tuple holds the results of a call of a function with several results
+type tuple []interface{}
+
+// funcRef returns the declared function expr names, instantiated when it
+// is generic, as Max[int] is
+func (e *simpleExecutor) funcRef(expr ast.Expr) (*funcValue, bool) {
+	switch x := expr.(type) {
+	case *ast.IndexExpr:
+		expr = x.X
+	case *ast.IndexListExpr:
+		expr = x.X
+	}
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := e.info.Uses[ident].(*types.Func); !ok {
+		return nil, false
+	}
+	fn, ok := e.functions[ident.Name]
+	if !ok {
+		return nil, false
+	}
+	// Resolved where the function is used, whose type parameters the type
+	// arguments may be
+	args := e.instantiate(fn, ident)
+	return &funcValue{
+		name:     fn.Name.Name,
+		instance: instanceName(fn.Name.Name, args),
+		typ:      fn.Type,
+		body:     fn.Body,
+		typeArgs: args,
+	}, true
+}
+
+// closure evaluates a function literal
+func (e *simpleExecutor) closure(lit *ast.FuncLit) *funcValue {
+	name := e.funcLits[lit]
+	return &funcValue{
+		name:     name,
+		instance: name,
+		typ:      lit.Type,
+		body:     lit.Body,
+		env:      e.scope,
+		typeArgs: e.typeArgs,
+	}
+}
+
+// methodValue returns the method sel selects bound to its receiver. The
+// method of a pointer receiver gets the address of an addressable value,
+// as x.M() is (&x).M(), and one of a value receiver a copy of the value.
+func (e *simpleExecutor) methodValue(sel *ast.SelectorExpr, selection *types.Selection) interface{} {
+	obj := selection.Obj().(*types.Func)
+	fn := e.methods[obj.Origin()]
+	if fn == nil {
+		// Such as a method of an interface
+		e.unsupported(sel, "method "+e.exprText(sel))
+		return nil
+	}
+
+	var self interface{}
+	_, wantPtr := obj.Type().(*types.Signature).Recv().Type().(*types.Pointer)
+	switch {
+	case wantPtr == isPointer(e.typeOf(sel.X)):
+		self = e.evalExpr(sel.X)
+	case wantPtr:
+		addr, ok := e.addressOf(sel.X)
+		if !ok {
+			e.fail(sel, "cannot take the address of %s", e.exprText(sel.X))
+		}
+		self = addr.Addr().Interface()
+	default:
+		self = deref(reflect.ValueOf(e.evalExpr(sel.X))).Interface()
+	}
+
+	// The type arguments of a generic type's method are those of the
+	// receiver's type, as int for a Stack[int], which names the method's
+	// instantiation, as Stack[int].Push
+	name := declName(fn)
+	instance := name
+	var args map[*types.TypeParam]types.Type
+	params := e.info.Defs[fn.Name].Type().(*types.Signature).RecvTypeParams()
+	if named, ok := e.concrete(derefType(selection.Recv())).(*types.Named); ok && params.Len() > 0 {
+		args = make(map[*types.TypeParam]types.Type, params.Len())
+		for i := 0; i < params.Len() && i < named.TypeArgs().Len(); i++ {
+			args[params.At(i)] = named.TypeArgs().At(i)
+		}
+		instance = typeString(named) + "." + fn.Name.Name
+	}
+
+	return &funcValue{
+		name:     name,
+		instance: instance,
+		typ:      fn.Type,
+		body:     fn.Body,
+		typeArgs: args,
+		recv:     fn.Recv,
+		self:     self,
+	}
+}
+
+// callee returns the function call calls, unless it is a builtin or a
+// package's function such as fmt.Println: a declared function or method,
+// or a function value held in a variable or returned by an expression
+func (e *simpleExecutor) callee(call *ast.CallExpr) (*funcValue, bool) {
+	if f, ok := e.funcRef(call.Fun); ok {
+		return f, true
+	}
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		if _, ok := e.info.Uses[fun].(*types.Builtin); ok {
+			return nil, false
+		}
+	case *ast.SelectorExpr:
+		selection := e.info.Selections[fun]
+		if selection == nil {
+			return nil, false
+		}
+		// Such as a method of an interface
+		if selection.Kind() == types.MethodVal && e.methods[selection.Obj().(*types.Func).Origin()] == nil {
+			return nil, false
+		}
+	}
+	if t := e.typeOf(call.Fun); t == nil {
+		return nil, false
+	} else if _, ok := t.Underlying().(*types.Signature); !ok {
+		return nil, false
+	}
+	f, _ := e.evalExpr(call.Fun).(*funcValue)
+	if f == nil {
+		runtimePanic("invalid memory address or nil pointer dereference")
+	}
+	return f, true
+}
+
+// callFunc calls f with the arguments of call, and returns its result
+func (e *simpleExecutor) callFunc(f *funcValue, call *ast.CallExpr) interface{} {
+	// The call as written, without the type arguments of a declared
+	// function or the body of a literal
+	label := e.exprText(call.Fun)
+	if _, ok := call.Fun.(*ast.FuncLit); ok {
+		label = f.name
+	} else if ident := calleeIdent(call); ident != nil {
+		if _, ok := e.info.Uses[ident].(*types.Func); ok {
+			label = ident.Name
+		}
+	}
+
+	// Safety: a deeper call can't be traced, and leaving it out would
+	// trace the caller with a wrong value
+	if len(e.callStack) >= e.maxCallDepth {
+		e.fail(call, "max call depth %d reached calling %s", e.maxCallDepth, label)
+	}
+
+	// Evaluate arguments in caller scope
+	args := e.evalArgs(call.Args)
+
+	// Record func_call step (in caller context)
+	line := e.fset.Position(call.Pos()).Line
+	e.addStep(line, "func_call", label+"(...)")
+
+	// Save caller state
+	frame := CallFrame{
+		FuncName:       f.instance,
+		SavedScope:     e.scope,
+		SavedReturned:  e.hasReturned,
+		SavedReturnVal: e.returnValue,
+		SavedTypeArgs:  e.typeArgs,
+		SavedResults:   e.results,
+	}
+
+	// Push call frame
+	e.callStack = append(e.callStack, frame)
+	e.typeArgs = f.typeArgs
+
+	// A declared function's blocks don't nest in the caller's; a function
+	// literal's nest in the block it was evaluated in
+	outer := e.pkg
+	if f.env != nil {
+		outer = f.env
+	}
+	e.scope = newScope(outer, f.name)
+
+	// Bind the receiver and parameters
+	if f.recv != nil {
+		for _, field := range f.recv.List {
+			for _, name := range field.Names {
+				e.declare(name, f.self)
+			}
+		}
+	}
+	if f.typ.Params != nil {
+		if n := len(f.typ.Params.List); n > 0 {
+			if _, ok := f.typ.Params.List[n-1].Type.(*ast.Ellipsis); ok {
+				e.unsupported(call, "call to variadic function "+label)
+			}
+		}
+		argIdx := 0
+		for _, field := range f.typ.Params.List {
+			for _, name := range field.Names {
+				if argIdx < len(args) {
+					e.declare(name, args[argIdx])
+					argIdx++
+				}
+			}
+		}
+	}
+
+	// Named results start out as zero values
+	e.results = nil
+	if f.typ.Results != nil && len(f.typ.Results.List[0].Names) > 0 {
+		e.results = f.typ.Results
+		for _, field := range f.typ.Results.List {
+			for _, name := range field.Names {
+				e.declare(name, zeroOf(e.concrete(e.info.Defs[name].Type())))
+			}
+		}
+	}
+
+	// Record func_enter step (in callee context)
+	if f.body != nil {
+		enterLine := e.fset.Position(f.body.Pos()).Line
+		e.addStep(enterLine, "func_enter", "enter "+frame.FuncName)
+	}
+
+	// Execute function body
+	e.hasReturned = false
+	e.returnValue = nil
+	if f.body != nil {
+		e.executeBlock(f.body.List)
+	}
+
+	// Capture return value
+	result := e.returnValue
+
+	// Pop call frame: restore caller state
+	e.callStack = e.callStack[:len(e.callStack)-1]
+	e.scope = frame.SavedScope
+	e.hasReturned = frame.SavedReturned
+	e.returnValue = frame.SavedReturnVal
+	e.typeArgs = frame.SavedTypeArgs
+	e.results = frame.SavedResults
+
+	return result
+}
+
+// declareMethod registers the method fn. fmt calls the String and Error
+// methods of the values it prints, which the interpreter's fmt doesn't.
+func (e *simpleExecutor) declareMethod(fn *ast.FuncDecl) {
+	obj, ok := e.info.Defs[fn.Name].(*types.Func)
+	if !ok {
+		e.unsupported(fn, "method declaration")
+		return
+	}
+	switch fn.Name.Name {
+	case "String", "Error", "GoString", "Format":
+		e.unsupported(fn, "method "+fn.Name.Name+", which fmt would call")
+	}
+	e.methods[obj] = fn
+}
+
+// declName names the function fn declares, with the receiver's type for a
+// method, as Stack.Push
+func declName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.IndexExpr:
+			typ = t.X
+			continue
+		case *ast.IndexListExpr:
+			typ = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
+
+// nameFuncLits names the function literals of file after the function
+// they are in, as the Go runtime does: the first literal in main is
+// main.func1, and the first literal in that one main.func1.1. Literals in
+// package-level declarations are glob.func1, glob.func2 and so on.
+func nameFuncLits(file *ast.File) map[*ast.FuncLit]string {
+	names := make(map[*ast.FuncLit]string)
+	var walk func(node ast.Node, outer, sep string, n *int)
+	walk = func(node ast.Node, outer, sep string, n *int) {
+		ast.Inspect(node, func(node ast.Node) bool {
+			lit, ok := node.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			*n++
+			names[lit] = fmt.Sprintf("%s%s%d", outer, sep, *n)
+			walk(lit.Body, names[lit], ".", new(int))
+			return false
+		})
+	}
+
+	globals := 0
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			if fn.Body != nil {
+				walk(fn.Body, declName(fn), ".func", new(int))
+			}
+		} else {
+			walk(decl, "glob", ".func", &globals)
+		}
+	}
+	return names
+}
+
+// namedResults returns the current values of the named results, as a
+// bare return does
+func (e *simpleExecutor) namedResults() interface{} {
+	var values tuple
+	for _, field := range e.results.List {
+		for _, name := range field.Names {
+			v, _ := e.lookupVar(name)
+			values = append(values, v)
+		}
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// evalArgs evaluates the arguments of a call, where f(g()) passes all of
+// g's results
+func (e *simpleExecutor) evalArgs(exprs []ast.Expr) []interface{} {
+	args := make([]interface{}, len(exprs))
+	for i, arg := range exprs {
+		args[i] = e.evalExpr(arg)
+	}
+	if len(args) == 1 {
+		if results, ok := args[0].(tuple); ok {
+			return results
+		}
+	}
+	return args
+}
+
+// evalMulti evaluates an expression giving n values: a call of a function
+// with n results, or a map index with the comma-ok form v, ok := m[k]
+func (e *simpleExecutor) evalMulti(expr ast.Expr, n int) []interface{} {
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		return e.evalMulti(x.X, n)
+	case *ast.CallExpr:
+		if results, ok := e.evalExpr(x).(tuple); ok && len(results) == n {
+			return results
+		}
+	case *ast.IndexExpr:
+		if mt, ok := e.typeOf(x.X).Underlying().(*types.Map); ok && n == 2 {
+			m, _ := e.evalExpr(x.X).(map[interface{}]interface{})
+			value, ok := m[e.evalExpr(x.Index)]
+			if !ok {
+				value = zeroOf(mt.Elem())
+			}
+			return []interface{}{value, ok}
+		}
+	}
+	e.unsupported(expr, "multi-value expression "+e.exprText(expr))
+	return make([]interface{}, n)
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMaxCallDepthStopsTheRun(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+func fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * fact(n-1)
+}
+
+func main() {
+	fmt.Println(fact(60))
+}
+`
+	_, err := Execute(code)
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("err = %v, want an *EvalError", err)
+	}
+	if evalErr.Line != 9 || !strings.Contains(evalErr.Message, "max call depth 50 reached") {
+		t.Errorf("err = %v, want max call depth reached at line 9", err)
+	}
+}
+
+func TestClosureAfterShadowing(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+func main() {
+	if v := 1; v > 0 {
+		f := func() int { return v }
+		v := 5
+		fmt.Println(f(), v)
+	}
+}
+`
+	result, err := Execute(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "1 5\n" {
+		t.Errorf("output = %q, want %q", result.Output, "1 5\n")
+	}
+}
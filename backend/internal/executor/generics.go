@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// concrete replaces the type parameters in typ with the type arguments of
+// the instantiation being executed, so that T is int inside Max[int]
+func (e *simpleExecutor) concrete(typ types.Type) types.Type {
+	if len(e.typeArgs) == 0 || typ == nil {
+		return typ
+	}
+	switch t := typ.(type) {
+	case *types.TypeParam:
+		if arg, ok := e.typeArgs[t]; ok {
+			return arg
+		}
+	case *types.Slice:
+		return types.NewSlice(e.concrete(t.Elem()))
+	case *types.Array:
+		return types.NewArray(e.concrete(t.Elem()), t.Len())
+	case *types.Map:
+		return types.NewMap(e.concrete(t.Key()), e.concrete(t.Elem()))
+	case *types.Pointer:
+		return types.NewPointer(e.concrete(t.Elem()))
+	case *types.Chan:
+		return types.NewChan(t.Dir(), e.concrete(t.Elem()))
+	case *types.Signature:
+		// A function type such as func(T) U; the type parameters of a
+		// generic function's own signature stay as they are
+		if t.TypeParams().Len() > 0 {
+			return typ
+		}
+		return types.NewSignatureType(nil, nil, nil, e.concreteTuple(t.Params()), e.concreteTuple(t.Results()), t.Variadic())
+	case *types.Named:
+		// A generic type instantiated with type parameters, as Stack[T]
+		if t.TypeArgs().Len() == 0 {
+			return typ
+		}
+		args := make([]types.Type, t.TypeArgs().Len())
+		for i := range args {
+			args[i] = e.concrete(t.TypeArgs().At(i))
+		}
+		if inst, err := types.Instantiate(nil, t.Origin(), args, false); err == nil {
+			return inst
+		}
+	}
+	return typ
+}
+
+// concreteTuple is concrete for the parameters or results of a signature
+func (e *simpleExecutor) concreteTuple(t *types.Tuple) *types.Tuple {
+	vars := make([]*types.Var, t.Len())
+	for i := range vars {
+		v := t.At(i)
+		vars[i] = types.NewParam(v.Pos(), v.Pkg(), v.Name(), e.concrete(v.Type()))
+	}
+	return types.NewTuple(vars...)
+}
+
+// instantiate returns the type arguments the use ident of the generic
+// function fn passes, whether written out as in Max[int](a, b) or inferred
+// as in Max(a, b), by type parameter. It returns nil for other functions.
+func (e *simpleExecutor) instantiate(fn *ast.FuncDecl, ident *ast.Ident) map[*types.TypeParam]types.Type {
+	obj, ok := e.info.Defs[fn.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	params := obj.Type().(*types.Signature).TypeParams()
+	inst, ok := e.info.Instances[ident]
+	if params.Len() == 0 || !ok {
+		return nil
+	}
+	args := make(map[*types.TypeParam]types.Type, params.Len())
+	for i := 0; i < params.Len() && i < inst.TypeArgs.Len(); i++ {
+		// Inside another generic function the arguments may be its own
+		// type parameters
+		args[params.At(i)] = e.concrete(inst.TypeArgs.At(i))
+	}
+	return args
+}
+
+// instanceName names the instantiation of the function name args makes,
+// as Map[int, string]
+func instanceName(name string, args map[*types.TypeParam]types.Type) string {
+	if len(args) == 0 {
+		return name
+	}
+	names := make([]string, len(args))
+	for param, arg := range args {
+		names[param.Index()] = typeString(arg)
+	}
+	return name + "[" + strings.Join(names, ", ") + "]"
+}
+
+// calleeIdent returns the name of the function call calls, without the
+// type arguments of an explicit instantiation, or nil
+func calleeIdent(call *ast.CallExpr) *ast.Ident {
+	fun := call.Fun
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		fun = f.X
+	case *ast.IndexListExpr:
+		fun = f.X
+	}
+	ident, _ := fun.(*ast.Ident)
+	return ident
+}
+
+// modelsType reports whether the interpreter can run code using the
+// declared type typ: constraint interfaces only restrict type arguments,
+// and a named or generic type's values are those of its underlying type.
+func modelsType(typ types.Type) bool {
+	if _, ok := typ.Underlying().(*types.Interface); ok {
+		return true
+	}
+	return reflectType(typ) != nil
+}
+
+// declareTypes checks the types decl declares: they need no run-time
+// state, but code using a type whose values the interpreter doesn't model,
+// such as a struct referring to itself, can't run
+func (e *simpleExecutor) declareTypes(decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.TypeSpec)
+		obj := e.info.Defs[spec.Name]
+		if obj == nil || !modelsType(obj.Type()) {
+			e.unsupported(spec, "declaration of type "+spec.Name.Name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package executor
+
+import "testing"
+
+func TestFuncParamTypeIsInstantiated(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+func Map[T, U any](s []T, f func(T) U, done chan T) []U {
+	out := make([]U, 0, len(s))
+	for _, v := range s {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func main() {
+	fmt.Println(Map([]int{1, 2}, func(x int) string { return "n" }, nil))
+}
+`
+	result, err := Execute(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Unsupported) > 0 {
+		t.Fatalf("unsupported: %v", result.Unsupported)
+	}
+
+	want := map[string]string{"f": "func(int) string", "done": "chan int", "s": "[]int"}
+	for _, step := range result.Steps {
+		if step.StatementType != "func_enter" || step.FunctionName != "Map[int, string]" {
+			continue
+		}
+		for _, v := range step.Variables {
+			if typ, ok := want[v.Name]; ok && v.Type != typ {
+				t.Errorf("%s has type %q, want %q", v.Name, v.Type, typ)
+			}
+			delete(want, v.Name)
+		}
+		if len(want) > 0 {
+			t.Errorf("variables missing on entering Map: %v", want)
+		}
+		return
+	}
+	t.Fatal("no step enters Map[int, string]")
+}
+
+func TestGenericMethodInstanceName(t *testing.T) {
+	code := `package main
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+func main() {
+	var s Stack[string]
+	s.Push("a")
+}
+`
+	result, err := Execute(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range result.Steps {
+		if step.StatementType == "func_enter" {
+			if step.Statement != "enter Stack[string].Push" {
+				t.Errorf("func_enter is %q, want %q", step.Statement, "enter Stack[string].Push")
+			}
+			return
+		}
+	}
+	t.Fatal("no func_enter step")
+}
@@ -0,0 +1,299 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goflow/visualizer/internal/sandbox"
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// NativeBuildTimeout bounds compiling a program in native mode, which happens
+// before the program runs under its own sandbox timeout
+const NativeBuildTimeout = 30 * time.Second
+
+// ExecuteNative instruments the code, compiles it with the local Go toolchain
+// and runs the binary inside the sandbox, returning the trace it recorded.
+// Unlike ExecuteSimple it handles the full language, at the cost of needing
+// `go` on the PATH. When a limit is hit the steps recorded so far are returned
+// together with a *sandbox.LimitError.
+func ExecuteNative(code string, limits sandbox.Config) ([]tracer.Step, string, error) {
+	// The trace calls use every variable they can see, so the compiler would
+	// no longer catch an unused one: the program is checked before them
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", code, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("parse error: %w", err)
+	}
+	if _, err := typeCheck(fset, file, goroot); err != nil {
+		return nil, "", err
+	}
+
+	instrumented, err := tracer.InstrumentCode(code)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, err := os.MkdirTemp("", "goflow-native-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("native error: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	binary, err := buildNative(dir, code, instrumented, limits)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return runNative(binary, dir, limits)
+}
+
+// BuildError reports that the program doesn't compile. Output is the
+// compiler's message, with positions in main.go.
+type BuildError struct {
+	Output string
+}
+
+func (e *BuildError) Error() string {
+	return "build error: " + e.Output
+}
+
+// NativeRun is the outcome of running a program with the real toolchain
+type NativeRun struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// RunNative compiles and runs code unmodified inside the sandbox, without
+// tracing. It is the reference the interpreter is checked against.
+func RunNative(code string, limits sandbox.Config) (*NativeRun, error) {
+	dir, err := os.MkdirTemp("", "goflow-native-*")
+	if err != nil {
+		return nil, fmt.Errorf("native error: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(code), 0o644); err != nil {
+		return nil, fmt.Errorf("native error: %w", err)
+	}
+	binary := filepath.Join(dir, "prog")
+	if err := goBuild(dir, binary, limits, "main.go"); err != nil {
+		return nil, err
+	}
+
+	stdout, err := os.CreateTemp("", "goflow-stdout-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("native error: %w", err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+
+	result, runErr := sandbox.Run(context.Background(), limits, sandbox.Process{
+		Path:   binary,
+		Dir:    dir,
+		Env:    []string{"HOME=" + dir, "TMPDIR=" + dir},
+		Stdout: stdout,
+	})
+	if result == nil {
+		return nil, runErr
+	}
+
+	output, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		return nil, fmt.Errorf("native error: %w", err)
+	}
+	return &NativeRun{
+		Stdout:   string(output),
+		Stderr:   result.Stderr,
+		ExitCode: result.ExitCode,
+	}, runErr
+}
+
+// buildNative writes the instrumented program and the trace runtime into dir
+// and compiles them, returning the path of the binary
+func buildNative(dir, original, instrumented string, limits sandbox.Config) (string, error) {
+	files := map[string]string{
+		"main.go":         instrumented,
+		"goflow_trace.go": tracer.TraceRuntime(),
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			return "", fmt.Errorf("native error: %w", err)
+		}
+	}
+
+	binary := filepath.Join(dir, "prog")
+	err := goBuild(dir, binary, limits, "main.go", "goflow_trace.go")
+	var build *BuildError
+	if !errors.As(err, &build) {
+		return binary, err
+	}
+	// Rebuild the untouched source so errors point at the user's lines
+	if err := os.WriteFile(filepath.Join(dir, "orig.go"), []byte(original), 0o644); err != nil {
+		return "", fmt.Errorf("native error: %w", err)
+	}
+	if err := goBuild(dir, filepath.Join(dir, "orig"), limits, "orig.go"); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("instrumentation error: %s", build.Output)
+}
+
+// goBuild compiles files in dir into output inside the sandbox, like the
+// program itself will run. Cgo is off, so no C compiler reads the source, and
+// the toolchain only sees the variables it needs. A program that doesn't
+// compile gives a *BuildError naming files[0] main.go.
+func goBuild(dir, output string, limits sandbox.Config, files ...string) error {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		return fmt.Errorf("native error: %w", err)
+	}
+	cache, err := buildCache()
+	if err != nil {
+		return fmt.Errorf("native error: %w", err)
+	}
+	log, err := os.CreateTemp(dir, "build-*.log")
+	if err != nil {
+		return fmt.Errorf("native error: %w", err)
+	}
+	defer log.Close()
+
+	// The toolchain needs more room than the programs it builds
+	cfg := limits
+	cfg.Timeout = NativeBuildTimeout
+	cfg.CPUTime = NativeBuildTimeout
+	cfg.MemoryBytes = nativeBuildMemory
+	cfg.FileBytes = 256 << 20
+	cfg.MaxOpenFiles = 1024
+	cfg.OutputBytes = 1 << 20
+
+	args := append([]string{"build", "-buildvcs=false", "-o", output}, files...)
+	result, err := sandbox.Run(context.Background(), cfg, sandbox.Process{
+		Path: goTool,
+		Args: args,
+		Dir:  dir,
+		Env: []string{
+			"PATH=" + filepath.Dir(goTool),
+			"HOME=" + dir,
+			"TMPDIR=" + dir,
+			"GOPATH=" + filepath.Join(dir, "gopath"),
+			"GOCACHE=" + cache,
+			"CGO_ENABLED=0",
+			"GOENV=off",
+			"GOFLAGS=",
+			"GOPROXY=off",
+			"GOTOOLCHAIN=local",
+			"GO111MODULE=on",
+		},
+		Stdout:        log,
+		WritablePaths: []string{dir, cache},
+	})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return &BuildError{Output: cleanBuildOutput(result.Stderr, files[0])}
+	}
+	return nil
+}
+
+// nativeBuildMemory bounds the memory of the compiler and linker
+const nativeBuildMemory = 2 << 30
+
+// buildCache returns the Go build cache shared by sandboxed builds. It only
+// ever holds the toolchain's output, as programs run without write access.
+func buildCache() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	dir := filepath.Join(base, "goflow", "go-build")
+	return dir, os.MkdirAll(dir, 0o700)
+}
+
+// cleanBuildOutput drops the package header line and renames the temp file
+// to main.go so messages read like the ones `go run main.go` prints
+func cleanBuildOutput(out, file string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "./")
+		lines = append(lines, strings.Replace(line, file, "main.go", 1))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runNative runs the binary in the sandbox with the trace and output files
+// wired up, and decodes the trace once it exits
+func runNative(binary, dir string, limits sandbox.Config) ([]tracer.Step, string, error) {
+	// Opened before the sandbox makes the temp filesystem read-only; the
+	// program only writes to them through inherited descriptors
+	traceFile, err := os.CreateTemp("", "goflow-trace-*.jsonl")
+	if err != nil {
+		return nil, "", fmt.Errorf("native error: %w", err)
+	}
+	defer os.Remove(traceFile.Name())
+	defer traceFile.Close()
+
+	stdout, err := os.CreateTemp("", "goflow-stdout-*.txt")
+	if err != nil {
+		return nil, "", fmt.Errorf("native error: %w", err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+
+	// A second handle lets the runtime attribute output to the step that printed it
+	stdoutReader, err := os.Open(stdout.Name())
+	if err != nil {
+		return nil, "", fmt.Errorf("native error: %w", err)
+	}
+	defer stdoutReader.Close()
+
+	result, runErr := sandbox.Run(context.Background(), limits, sandbox.Process{
+		Path: binary,
+		Dir:  dir,
+		Env: []string{
+			"HOME=" + dir,
+			"TMPDIR=" + dir,
+			// ExtraFiles[i] becomes fd 3+i in the child
+			tracer.TraceFDEnv + "=3",
+			tracer.OutputFDEnv + "=4",
+		},
+		Stdout:     stdout,
+		ExtraFiles: []*os.File{traceFile, stdoutReader},
+	})
+	if result == nil {
+		return nil, "", runErr
+	}
+
+	output, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		return nil, "", fmt.Errorf("native error: %w", err)
+	}
+	if limits.OutputBytes > 0 && int64(len(output)) > limits.OutputBytes {
+		output = output[:limits.OutputBytes]
+	}
+	// Panics and non-zero exits are part of what the program printed
+	finalOutput := string(output) + result.Stderr
+
+	if _, err := traceFile.Seek(0, io.SeekStart); err != nil {
+		return nil, "", fmt.Errorf("native error: %w", err)
+	}
+	steps, err := tracer.DecodeTrace(traceFile)
+	if err != nil && runErr == nil {
+		return nil, "", err
+	}
+
+	return steps, finalOutput, runErr
+}
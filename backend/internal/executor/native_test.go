@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goflow/visualizer/internal/sandbox"
+)
+
+func TestNativeRejectsUnusedVariable(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+func main() {
+	x := 1
+	y := 2
+	fmt.Println(x)
+}
+`
+	// The trace calls use y, so only checking the source before they are
+	// added catches it
+	_, _, err := ExecuteNative(code, sandbox.DefaultConfig())
+	var typeErr *TypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("err = %v, want a *TypeError", err)
+	}
+	if p := typeErr.Problems[0]; p.Line != 7 || p.Message != "declared and not used: y" {
+		t.Errorf("problem = %v, want line 7: declared and not used: y", p)
+	}
+}
@@ -0,0 +1,403 @@
+package executor
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+// goPanic is a run-time panic of the interpreted program, such as an integer
+// division by zero. It unwinds the interpreter up to Execute, which ends the
+// trace with it the way the program would have died.
+type goPanic struct {
+	msg string
+}
+
+func runtimePanic(msg string) {
+	panic(goPanic{msg: "runtime error: " + msg})
+}
+
+// basicTypes are the Go types interpreter values have for each basic kind
+var basicTypes = map[types.BasicKind]reflect.Type{
+	types.Bool:       reflect.TypeOf(false),
+	types.Int:        reflect.TypeOf(int(0)),
+	types.Int8:       reflect.TypeOf(int8(0)),
+	types.Int16:      reflect.TypeOf(int16(0)),
+	types.Int32:      reflect.TypeOf(int32(0)),
+	types.Int64:      reflect.TypeOf(int64(0)),
+	types.Uint:       reflect.TypeOf(uint(0)),
+	types.Uint8:      reflect.TypeOf(uint8(0)),
+	types.Uint16:     reflect.TypeOf(uint16(0)),
+	types.Uint32:     reflect.TypeOf(uint32(0)),
+	types.Uint64:     reflect.TypeOf(uint64(0)),
+	types.Uintptr:    reflect.TypeOf(uintptr(0)),
+	types.Float32:    reflect.TypeOf(float32(0)),
+	types.Float64:    reflect.TypeOf(float64(0)),
+	types.Complex64:  reflect.TypeOf(complex64(0)),
+	types.Complex128: reflect.TypeOf(complex128(0)),
+	types.String:     reflect.TypeOf(""),
+}
+
+// defaultKinds gives the type an untyped constant takes when nothing else
+// decides it, as in x := 1.5
+var defaultKinds = map[types.BasicKind]types.BasicKind{
+	types.UntypedBool:    types.Bool,
+	types.UntypedInt:     types.Int,
+	types.UntypedRune:    types.Int32,
+	types.UntypedFloat:   types.Float64,
+	types.UntypedComplex: types.Complex128,
+	types.UntypedString:  types.String,
+}
+
+// constValue converts a constant to the value of type typ. The type checker
+// has already applied the untyped-constant rules, so 1 in f + 1 arrives
+// here with f's type.
+func constValue(val constant.Value, typ types.Type) interface{} {
+	basic, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return nil
+	}
+	kind := basic.Kind()
+	if k, ok := defaultKinds[kind]; ok {
+		kind = k
+	}
+	goType, ok := basicTypes[kind]
+	if !ok {
+		return nil
+	}
+
+	var v reflect.Value
+	switch {
+	case kind == types.Bool:
+		v = reflect.ValueOf(constant.BoolVal(val))
+	case kind == types.String:
+		v = reflect.ValueOf(constant.StringVal(val))
+	case isUnsignedKind(kind):
+		u, _ := constant.Uint64Val(constant.ToInt(val))
+		v = reflect.ValueOf(u)
+	case isIntegerKind(kind):
+		i, _ := constant.Int64Val(constant.ToInt(val))
+		v = reflect.ValueOf(i)
+	case kind == types.Float32 || kind == types.Float64:
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		v = reflect.ValueOf(f)
+	default:
+		re, _ := constant.Float64Val(constant.ToFloat(constant.Real(val)))
+		im, _ := constant.Float64Val(constant.ToFloat(constant.Imag(val)))
+		v = reflect.ValueOf(complex(re, im))
+	}
+	return v.Convert(goType).Interface()
+}
+
+func isIntegerKind(kind types.BasicKind) bool {
+	return kind >= types.Int && kind <= types.Uintptr
+}
+
+func isUnsignedKind(kind types.BasicKind) bool {
+	return kind >= types.Uint && kind <= types.Uintptr
+}
+
+// evalBinary applies a binary operator to two values of the same type, or
+// a shift to an integer and an unsigned count. Arithmetic is done in the
+// widest type of the operands' kind and converted back, which wraps around
+// exactly like Go's fixed-size arithmetic. It returns nil for operands the
+// operator doesn't apply to.
+func (e *simpleExecutor) evalBinary(left, right interface{}, op token.Token) interface{} {
+	if op == token.SHL || op == token.SHR {
+		return shift(left, right, op)
+	}
+	if op == token.EQL || op == token.NEQ {
+		eq, ok := equal(left, right)
+		if !ok {
+			return nil
+		}
+		return eq == (op == token.EQL)
+	}
+
+	x, y := reflect.ValueOf(left), reflect.ValueOf(right)
+	if !x.IsValid() || !y.IsValid() || x.Type() != y.Type() {
+		return nil
+	}
+
+	var result interface{}
+	switch x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result = signedOp(x.Int(), y.Int(), op)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result = unsignedOp(x.Uint(), y.Uint(), op)
+	case reflect.Float32, reflect.Float64:
+		result = floatOp(x.Float(), y.Float(), op)
+	case reflect.Complex64, reflect.Complex128:
+		result = complexOp(x.Complex(), y.Complex(), op)
+	case reflect.String:
+		result = stringOp(x.String(), y.String(), op)
+	case reflect.Bool:
+		result = boolOp(x.Bool(), y.Bool(), op)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if _, isBool := result.(bool); isBool && x.Kind() != reflect.Bool {
+		// Comparisons give an untyped bool, not the operands' type
+		return result
+	}
+	return reflect.ValueOf(result).Convert(x.Type()).Interface()
+}
+
+func signedOp(x, y int64, op token.Token) interface{} {
+	switch op {
+	case token.ADD:
+		return x + y
+	case token.SUB:
+		return x - y
+	case token.MUL:
+		return x * y
+	case token.QUO:
+		if y == 0 {
+			runtimePanic("integer divide by zero")
+		}
+		return x / y
+	case token.REM:
+		if y == 0 {
+			runtimePanic("integer divide by zero")
+		}
+		return x % y
+	case token.AND:
+		return x & y
+	case token.OR:
+		return x | y
+	case token.XOR:
+		return x ^ y
+	case token.AND_NOT:
+		return x &^ y
+	}
+	return ordered(x, y, op)
+}
+
+func unsignedOp(x, y uint64, op token.Token) interface{} {
+	switch op {
+	case token.ADD:
+		return x + y
+	case token.SUB:
+		return x - y
+	case token.MUL:
+		return x * y
+	case token.QUO:
+		if y == 0 {
+			runtimePanic("integer divide by zero")
+		}
+		return x / y
+	case token.REM:
+		if y == 0 {
+			runtimePanic("integer divide by zero")
+		}
+		return x % y
+	case token.AND:
+		return x & y
+	case token.OR:
+		return x | y
+	case token.XOR:
+		return x ^ y
+	case token.AND_NOT:
+		return x &^ y
+	}
+	return ordered(x, y, op)
+}
+
+func floatOp(x, y float64, op token.Token) interface{} {
+	switch op {
+	case token.ADD:
+		return x + y
+	case token.SUB:
+		return x - y
+	case token.MUL:
+		return x * y
+	case token.QUO:
+		// Floating-point division by zero gives ±Inf or NaN, not a panic
+		return x / y
+	}
+	return ordered(x, y, op)
+}
+
+func complexOp(x, y complex128, op token.Token) interface{} {
+	switch op {
+	case token.ADD:
+		return x + y
+	case token.SUB:
+		return x - y
+	case token.MUL:
+		return x * y
+	case token.QUO:
+		return x / y
+	}
+	return nil
+}
+
+func stringOp(x, y string, op token.Token) interface{} {
+	if op == token.ADD {
+		return x + y
+	}
+	return ordered(x, y, op)
+}
+
+func boolOp(x, y bool, op token.Token) interface{} {
+	switch op {
+	case token.LAND:
+		return x && y
+	case token.LOR:
+		return x || y
+	}
+	return nil
+}
+
+// ordered applies a comparison operator
+func ordered[T int64 | uint64 | float64 | string](x, y T, op token.Token) interface{} {
+	switch op {
+	case token.LSS:
+		return x < y
+	case token.LEQ:
+		return x <= y
+	case token.GTR:
+		return x > y
+	case token.GEQ:
+		return x >= y
+	}
+	return nil
+}
+
+// equal compares two values with ==. ok is false when Go wouldn't allow
+// the comparison.
+func equal(x, y interface{}) (eq, ok bool) {
+	if x == nil || y == nil {
+		return isNil(x) && isNil(y), true
+	}
+	if reflect.TypeOf(x) != reflect.TypeOf(y) || !reflect.TypeOf(x).Comparable() {
+		return false, false
+	}
+	return x == y, true
+}
+
+// isNil reports whether v is nil or a nil slice, map or pointer
+func isNil(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Func, reflect.Chan, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// shift shifts an integer by a count of any integer type
+func shift(left, right interface{}, op token.Token) interface{} {
+	x, y := reflect.ValueOf(left), reflect.ValueOf(right)
+	if !x.IsValid() || !y.IsValid() {
+		return nil
+	}
+
+	var count uint64
+	switch y.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if y.Int() < 0 {
+			runtimePanic("negative shift amount")
+		}
+		count = uint64(y.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		count = y.Uint()
+	default:
+		return nil
+	}
+
+	var result interface{}
+	switch x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if op == token.SHL {
+			result = x.Int() << count
+		} else {
+			result = x.Int() >> count
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if op == token.SHL {
+			result = x.Uint() << count
+		} else {
+			result = x.Uint() >> count
+		}
+	default:
+		return nil
+	}
+	return reflect.ValueOf(result).Convert(x.Type()).Interface()
+}
+
+// one returns 1 in the type of v, for ++ and --
+func one(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(1).Convert(rv.Type()).Interface()
+	case reflect.Complex64, reflect.Complex128:
+		return reflect.ValueOf(complex(1, 0)).Convert(rv.Type()).Interface()
+	}
+	return nil
+}
+
+// evalUnary applies a unary operator. It returns nil for an operand the
+// operator doesn't apply to.
+func evalUnary(op token.Token, operand interface{}) interface{} {
+	x := reflect.ValueOf(operand)
+	if !x.IsValid() {
+		return nil
+	}
+
+	var result interface{}
+	switch x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch op {
+		case token.ADD:
+			result = x.Int()
+		case token.SUB:
+			result = -x.Int()
+		case token.XOR:
+			result = ^x.Int()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch op {
+		case token.ADD:
+			result = x.Uint()
+		case token.SUB:
+			result = -x.Uint()
+		case token.XOR:
+			result = ^x.Uint()
+		}
+	case reflect.Float32, reflect.Float64:
+		switch op {
+		case token.ADD:
+			result = x.Float()
+		case token.SUB:
+			result = -x.Float()
+		}
+	case reflect.Complex64, reflect.Complex128:
+		switch op {
+		case token.ADD:
+			result = x.Complex()
+		case token.SUB:
+			result = -x.Complex()
+		}
+	case reflect.Bool:
+		if op == token.NOT {
+			result = !x.Bool()
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	// Converting back truncates, so -x of the most negative int8 stays put
+	// and ^x of an unsigned value keeps only its own bits
+	return reflect.ValueOf(result).Convert(x.Type()).Interface()
+}
@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// runFunc runs init or main, which the runtime calls with nothing on the
+// call stack
+func (e *simpleExecutor) runFunc(fn *ast.FuncDecl) {
+	e.callStack = []CallFrame{{FuncName: fn.Name.Name}}
+	e.scope = newScope(e.pkg, fn.Name.Name)
+	e.hasReturned = false
+	if fn.Body != nil {
+		e.executeBlock(fn.Body.List)
+	}
+}
+
+// initPackage declares the package-level constants and variables, then
+// initializes the variables in dependency order, as the Go runtime does
+// before init and main run. Each initialization is a declare step.
+func (e *simpleExecutor) initPackage(file *ast.File) {
+	e.callStack = []CallFrame{{FuncName: "init"}}
+	e.scope = e.pkg
+
+	idents := make(map[*types.Var]*ast.Ident)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR && gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				switch obj := e.info.Defs[name].(type) {
+				case *types.Const:
+					e.declare(name, constValue(obj.Val(), obj.Type()))
+				case *types.Var:
+					e.declare(name, zeroOf(obj.Type()))
+					idents[obj] = name
+				}
+			}
+		}
+	}
+
+	// The type checker has worked out the order: a variable is initialized
+	// after the variables and functions its initializer refers to
+	for _, init := range e.info.InitOrder {
+		if len(init.Lhs) != 1 {
+			e.unsupported(init.Rhs, "package-level initialization from a multi-value expression")
+			continue
+		}
+		name := idents[init.Lhs[0]]
+		if name == nil {
+			continue
+		}
+		line := e.fset.Position(name.Pos()).Line
+		e.line = line
+		e.store(name, e.evalExpr(init.Rhs))
+		e.addStep(line, "declare", "var "+name.Name+" = "+e.exprText(init.Rhs))
+	}
+}
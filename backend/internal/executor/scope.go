@@ -0,0 +1,245 @@
+package executor
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// scope is a Go block: the variables declared directly in it, and the block
+// it is nested in. Lookups walk outward, so an inner x := ... shadows an outer
+// x until the inner block ends.
+type scope struct {
+	// name is the path of the block, such as main.for_1.if_2. A body shares
+	// the name of the statement it belongs to: the variables of for i := ...
+	// and those declared in its body are both in for_1, although the body may
+	// shadow i.
+	name   string
+	parent *scope
+	// vars holds the variables' values, except that an array or struct
+	// variable holds the reflect.Value of its storage, so slicing it or
+	// taking its address can share it
+	vars  map[string]interface{}
+	types map[string]string
+	// objs holds the object each variable was declared as, so that an
+	// identifier finds its own variable even when a later declaration in
+	// the block shadows it, as it may after a closure using it
+	objs map[string]types.Object
+	// order lists the variables in the order they were declared
+	order []string
+}
+
+func newScope(parent *scope, name string) *scope {
+	return &scope{
+		name:   name,
+		parent: parent,
+		vars:   make(map[string]interface{}),
+		types:  make(map[string]string),
+		objs:   make(map[string]types.Object),
+	}
+}
+
+// lookup returns the innermost block declaring name, or nil
+func (s *scope) lookup(name string) *scope {
+	for ; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// lookupObj returns the innermost block declaring name as obj, or nil.
+// A nil obj, or a variable declared without one, matches by name.
+func (s *scope) lookupObj(name string, obj types.Object) *scope {
+	for ; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok && (obj == nil || s.objs[name] == nil || s.objs[name] == obj) {
+			return s
+		}
+	}
+	return nil
+}
+
+// get returns the value of the variable name declared in s
+func (s *scope) get(name string) interface{} {
+	if cell, ok := s.vars[name].(reflect.Value); ok {
+		// Reading an array or struct copies it
+		return cell.Interface()
+	}
+	return s.vars[name]
+}
+
+// declare sets the variable name in s, creating it if needed
+func (s *scope) declare(name string, value interface{}, typ string) {
+	old, exists := s.vars[name]
+	if !exists {
+		s.order = append(s.order, name)
+	}
+	s.types[name] = typ
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Array && v.Kind() != reflect.Struct {
+		s.vars[name] = value
+		return
+	}
+	// Assigning to an array or struct overwrites its storage, which slices
+	// of it and pointers to it see
+	if cell, ok := old.(reflect.Value); ok && cell.Type() == v.Type() {
+		cell.Set(v)
+		return
+	}
+	cell := reflect.New(v.Type()).Elem()
+	cell.Set(v)
+	s.vars[name] = cell
+}
+
+// clone returns a new block with copies of s's variables. Since Go 1.22 each
+// iteration of a for loop has its own copy of the loop variables.
+func (s *scope) clone() *scope {
+	c := newScope(s.parent, s.name)
+	for _, name := range s.order {
+		c.declare(name, s.get(name), s.types[name])
+		c.objs[name] = s.objs[name]
+	}
+	return c
+}
+
+// pushScope enters a block named name inside the current one; an empty name
+// is a body sharing the name of its statement
+func (e *simpleExecutor) pushScope(name string) {
+	if name != "" {
+		name = e.scope.name + "." + name
+	} else {
+		name = e.scope.name
+	}
+	e.scope = newScope(e.scope, name)
+}
+
+func (e *simpleExecutor) popScope() {
+	e.scope = e.scope.parent
+}
+
+// scopeStack returns the names of the enclosing blocks, outermost first
+func (e *simpleExecutor) scopeStack() []string {
+	return strings.Split(e.scope.name, ".")
+}
+
+// declare creates the variable ident names in the current block
+func (e *simpleExecutor) declare(ident *ast.Ident, value interface{}) {
+	if ident.Name != "_" {
+		e.scope.declare(ident.Name, value, e.varType(ident, value))
+		e.scope.objs[ident.Name] = e.info.ObjectOf(ident)
+	}
+}
+
+// define implements ident := value: it declares ident unless the current
+// block already has it, in which case it assigns
+func (e *simpleExecutor) define(ident *ast.Ident, value interface{}) {
+	if _, ok := e.scope.vars[ident.Name]; ok {
+		e.store(ident, value)
+		return
+	}
+	e.declare(ident, value)
+}
+
+// lookupIdent returns the block declaring the variable ident refers to, or
+// nil
+func (e *simpleExecutor) lookupIdent(ident *ast.Ident) *scope {
+	return e.scope.lookupObj(ident.Name, e.info.ObjectOf(ident))
+}
+
+// lookupVar returns the value of the variable ident refers to
+func (e *simpleExecutor) lookupVar(ident *ast.Ident) (interface{}, bool) {
+	if s := e.lookupIdent(ident); s != nil {
+		return s.get(ident.Name), true
+	}
+	return nil, false
+}
+
+// addressOf returns the storage expr denotes when it is addressable, as
+// an array variable, a slice element or a struct field is, so that writes
+// through the result and slices of it change that variable or element
+func (e *simpleExecutor) addressOf(expr ast.Expr) (reflect.Value, bool) {
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		return e.addressOf(x.X)
+	case *ast.Ident:
+		if s := e.lookupIdent(x); s != nil {
+			cell, ok := s.vars[x.Name].(reflect.Value)
+			return cell, ok
+		}
+	case *ast.IndexExpr:
+		collection := reflect.ValueOf(e.evalExpr(x.X))
+		if collection.Kind() == reflect.Array {
+			var ok bool
+			if collection, ok = e.addressOf(x.X); !ok {
+				return reflect.Value{}, false
+			}
+		} else if collection.Kind() != reflect.Slice {
+			return reflect.Value{}, false
+		}
+		return collection.Index(e.checkIndex(e.evalExpr(x.Index), collection.Len())), true
+	case *ast.SelectorExpr:
+		selection := e.info.Selections[x]
+		if selection == nil || selection.Kind() != types.FieldVal {
+			return reflect.Value{}, false
+		}
+		// A field of a struct a pointer points at is addressable whatever
+		// the pointer is
+		if isPointer(e.typeOf(x.X)) {
+			return e.fieldOf(reflect.ValueOf(e.evalExpr(x.X)), selection.Index()), true
+		}
+		base, ok := e.addressOf(x.X)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return e.fieldOf(base, selection.Index()), true
+	case *ast.StarExpr:
+		return deref(reflect.ValueOf(e.evalExpr(x.X))), true
+	}
+	return reflect.Value{}, false
+}
+
+// numberBlocks numbers the if, switch and explicit blocks of file in source
+// order, as the native tracer does, so both name a block the same way
+func numberBlocks(file *ast.File) map[ast.Node]int {
+	ids := make(map[ast.Node]int)
+	explicit := make(map[ast.Stmt]bool)
+	markBlocks := func(stmts []ast.Stmt) {
+		for _, stmt := range stmts {
+			if block, ok := stmt.(*ast.BlockStmt); ok {
+				explicit[block] = true
+			}
+		}
+	}
+	var lits []*ast.FuncLit
+	visit := func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			lits = append(lits, n)
+			return false
+		case *ast.IfStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			ids[n] = len(ids) + 1
+		case *ast.BlockStmt:
+			if explicit[n] {
+				ids[n] = len(ids) + 1
+			}
+			markBlocks(n.List)
+		case *ast.CaseClause:
+			markBlocks(n.Body)
+		case *ast.CommClause:
+			markBlocks(n.Body)
+		case *ast.LabeledStmt:
+			markBlocks([]ast.Stmt{n.Stmt})
+		}
+		return true
+	}
+	ast.Inspect(file, visit)
+	// The native tracer leaves function literals out, so their blocks are
+	// numbered after all the others
+	for i := 0; i < len(lits); i++ {
+		ast.Inspect(lits[i].Body, visit)
+	}
+	return ids
+}
@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"unsafe"
+)
+
+// Struct values are reflect.StructOf structs, and pointers point at them.
+// Like arrays, struct variables are kept in addressable cells, so that a
+// pointer to one and the variable share it.
+
+// field returns field i of the struct v, settable when v is addressable.
+// The program may use its unexported fields, which reflect doesn't allow.
+func field(v reflect.Value, i int) reflect.Value {
+	if !v.CanAddr() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	f := v.Field(i)
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// deref returns the struct the pointer p points at, panicking like Go when
+// it is nil
+func deref(p reflect.Value) reflect.Value {
+	if !p.IsValid() || p.Kind() == reflect.Ptr && p.IsNil() {
+		runtimePanic("invalid memory address or nil pointer dereference")
+	}
+	if p.Kind() == reflect.Ptr {
+		return p.Elem()
+	}
+	return p
+}
+
+// isPointer reports whether typ is a pointer type
+func isPointer(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	_, ok := typ.Underlying().(*types.Pointer)
+	return ok
+}
+
+// derefType returns the type a pointer type points to, or typ itself
+func derefType(typ types.Type) types.Type {
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return typ
+}
+
+// evalSelector evaluates x.f, which selects a field or a method of x
+func (e *simpleExecutor) evalSelector(sel *ast.SelectorExpr) interface{} {
+	selection := e.info.Selections[sel]
+	switch {
+	case selection == nil:
+		// Such as fmt.Println used as a value
+	case selection.Kind() == types.FieldVal:
+		return e.fieldOf(reflect.ValueOf(e.evalExpr(sel.X)), selection.Index()).Interface()
+	case selection.Kind() == types.MethodVal:
+		return e.methodValue(sel, selection)
+	}
+	e.unsupported(sel, "expression "+e.exprText(sel))
+	return nil
+}
+
+// fieldOf follows the field indices path from the struct v, or a pointer
+// to it, through pointers on the way
+func (e *simpleExecutor) fieldOf(v reflect.Value, path []int) reflect.Value {
+	for _, i := range path {
+		v = field(deref(v), i)
+	}
+	return v
+}
+
+// evalStructLit builds a struct literal, whose elements are either field:
+// value pairs or the values of all the fields in order
+func (e *simpleExecutor) evalStructLit(lit *ast.CompositeLit, rt reflect.Type) interface{} {
+	result := reflect.New(rt).Elem()
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			f, _ := rt.FieldByName(kv.Key.(*ast.Ident).Name)
+			i, elt = f.Index[0], kv.Value
+		}
+		field(result, i).Set(e.assignable(elt, e.evalExpr(elt), rt.Field(i).Type))
+	}
+	return result.Interface()
+}
+
+// evalAddress evaluates &x. &T{...} allocates a new struct, and the address
+// of a struct variable, field or element shares its storage.
+func (e *simpleExecutor) evalAddress(ex *ast.UnaryExpr) interface{} {
+	if reflectType(e.typeOf(ex)) == nil {
+		e.unsupported(ex, "pointer to "+typeString(e.typeOf(ex.X)))
+		return nil
+	}
+	if lit, ok := ex.X.(*ast.CompositeLit); ok {
+		v := reflect.ValueOf(e.evalExpr(lit))
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface()
+	}
+	addr, ok := e.addressOf(ex.X)
+	if !ok {
+		e.fail(ex, "cannot take the address of %s", e.exprText(ex.X))
+	}
+	return addr.Addr().Interface()
+}
@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// stdlibStubs declares the parts of the standard library the interpreter
+// implements, so programs type-check without a Go installation
+var stdlibStubs = map[string]string{
+	"fmt": `package fmt
+
+func Print(a ...any) (n int, err error)                 { return }
+func Println(a ...any) (n int, err error)               { return }
+func Printf(format string, a ...any) (n int, err error) { return }
+func Sprint(a ...any) string                            { return "" }
+func Sprintln(a ...any) string                          { return "" }
+func Sprintf(format string, a ...any) string            { return "" }
+func Errorf(format string, a ...any) error              { return nil }
+`,
+	"cmp": `package cmp
+
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 |
+		~string
+}
+
+func Less[T Ordered](x, y T) bool   { return false }
+func Compare[T Ordered](x, y T) int { return 0 }
+`,
+}
+
+// stubImporter resolves imports from stdlibStubs
+type stubImporter struct {
+	fset     *token.FileSet
+	packages map[string]*types.Package
+}
+
+func (im *stubImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := im.packages[path]; ok {
+		return pkg, nil
+	}
+	src, ok := stdlibStubs[path]
+	if !ok {
+		return nil, fmt.Errorf("package %s is not supported by the interpreter", path)
+	}
+	file, err := parser.ParseFile(im.fset, path+".go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := new(types.Config).Check(path, im.fset, []*ast.File{file}, nil)
+	if err != nil {
+		return nil, err
+	}
+	im.packages[path] = pkg
+	return pkg, nil
+}
+
+// stdImporter resolves imports from the standard library sources of the
+// local Go installation, for programs run natively. Packages are checked once
+// and shared by every program.
+type stdImporter struct {
+	mu       sync.Mutex
+	importer types.Importer
+}
+
+var goroot = &stdImporter{importer: importer.ForCompiler(token.NewFileSet(), "source", nil)}
+
+func (im *stdImporter) Import(path string) (*types.Package, error) {
+	// Only the standard library is there to build against, and reading
+	// packages from anywhere else would let a program list the server's files
+	if build.IsLocalImport(path) || strings.Contains(strings.Split(path, "/")[0], ".") {
+		return nil, fmt.Errorf("package %s is not in the standard library", path)
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	return im.importer.Import(path)
+}
+
+// typeCheck records the type and constant value of every expression, and
+// the variable each identifier refers to. It returns a *TypeError listing
+// every type error in the program, as the compiler would. Imports are
+// resolved by im.
+func typeCheck(fset *token.FileSet, file *ast.File, im types.Importer) (*types.Info, error) {
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+		// The type arguments of each use of a generic function or type
+		Instances: make(map[*ast.Ident]types.Instance),
+		// The field or method each selector picks
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	var problems []Problem
+	conf := types.Config{
+		Importer: im,
+		Error: func(err error) {
+			if terr, ok := err.(types.Error); ok {
+				pos := fset.Position(terr.Pos)
+				problems = append(problems, Problem{Line: pos.Line, Column: pos.Column, Message: terr.Msg})
+			}
+		},
+	}
+	conf.Check("main", fset, []*ast.File{file}, info)
+	if len(problems) > 0 {
+		return info, &TypeError{Problems: problems}
+	}
+	return info, nil
+}
+
+// unsupportedImports returns the imports of file the interpreter has no
+// implementation of
+func unsupportedImports(file *ast.File) []*ast.ImportSpec {
+	var specs []*ast.ImportSpec
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if _, ok := stdlibStubs[path]; err != nil || !ok {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Interpreter values are Go values of the matching type: an int8 variable
+// holds an int8, a []uint16 a []uint16 and a [3][3]string a [3][3]string.
+// Maps are map[interface{}]interface{} whatever their key and element types,
+// and a value of a named type holds its underlying type. Structs are built
+// with reflect.StructOf, and functions are *funcValue.
+
+var (
+	mapType   = reflect.TypeOf(map[interface{}]interface{}(nil))
+	emptyType = reflect.TypeOf((*interface{})(nil)).Elem()
+	funcType  = reflect.TypeOf((*funcValue)(nil))
+)
+
+// reflectType returns the Go type interpreter values of typ have, or nil
+// when the interpreter doesn't model typ
+func reflectType(typ types.Type) reflect.Type {
+	return reflectTypeIn(typ, nil)
+}
+
+// reflectTypeIn is reflectType for a type nested in the named types outer.
+// A type referring back to one of them, as a linked list node does, isn't
+// modeled.
+func reflectTypeIn(typ types.Type, outer []*types.Named) reflect.Type {
+	if named, ok := typ.(*types.Named); ok {
+		for _, n := range outer {
+			if types.Identical(n, named) {
+				return nil
+			}
+		}
+		outer = append(outer, named)
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		kind := t.Kind()
+		if k, ok := defaultKinds[kind]; ok {
+			kind = k
+		}
+		return basicTypes[kind]
+	case *types.Slice:
+		if elem := reflectTypeIn(t.Elem(), outer); elem != nil {
+			return reflect.SliceOf(elem)
+		}
+	case *types.Array:
+		if elem := reflectTypeIn(t.Elem(), outer); elem != nil {
+			return reflect.ArrayOf(int(t.Len()), elem)
+		}
+	case *types.Map:
+		return mapType
+	case *types.Interface:
+		return emptyType
+	case *types.Signature:
+		return funcType
+	case *types.Struct:
+		return structType(t, outer)
+	case *types.Pointer:
+		// Only pointers to structs: other variables aren't addressable
+		if elem := reflectTypeIn(t.Elem(), outer); elem != nil && elem.Kind() == reflect.Struct {
+			return reflect.PointerTo(elem)
+		}
+	}
+	return nil
+}
+
+// structType returns the Go struct type with the fields of t, or nil when
+// the interpreter doesn't model one of them. Unexported fields belong to
+// package main, so the values print as the program's would.
+func structType(t *types.Struct, outer []*types.Named) reflect.Type {
+	fields := make([]reflect.StructField, t.NumFields())
+	for i := range fields {
+		f := t.Field(i)
+		if f.Embedded() || f.Name() == "_" {
+			return nil
+		}
+		rt := reflectTypeIn(f.Type(), outer)
+		if rt == nil {
+			return nil
+		}
+		fields[i] = reflect.StructField{Name: f.Name(), Type: rt}
+		if !f.Exported() {
+			fields[i].PkgPath = "main"
+		}
+	}
+	return reflect.StructOf(fields)
+}
+
+// zeroOf returns the zero value of typ, or nil for types the interpreter
+// doesn't model
+func zeroOf(typ types.Type) interface{} {
+	if rt := reflectType(typ); rt != nil && rt != emptyType {
+		return reflect.Zero(rt).Interface()
+	}
+	return nil
+}
+
+// convert implements the conversion T(v). Between numeric types it behaves
+// like the compiled program: integers wrap to the target size and floats
+// are truncated toward zero.
+func convert(v interface{}, to types.Type) (interface{}, bool) {
+	rt := reflectType(to)
+	if rt == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		// nil converted to a slice, map or interface type
+		return reflect.Zero(rt).Interface(), true
+	}
+	if rt == emptyType {
+		return v, true
+	}
+	if !rv.Type().ConvertibleTo(rt) {
+		return nil, false
+	}
+	return rv.Convert(rt).Interface(), true
+}
+
+// typeString formats a type the way the program spells it, without the
+// main package qualifier
+func typeString(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string {
+		if pkg.Name() == "main" {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+// varType returns the static type of the variable ident names, falling back
+// to the type of its current value
+func (e *simpleExecutor) varType(ident *ast.Ident, value interface{}) string {
+	switch obj := e.info.ObjectOf(ident).(type) {
+	case *types.Var, *types.Const:
+		return typeString(e.concrete(obj.Type()))
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// typeOf returns the type the type checker gave expr, or nil
+func (e *simpleExecutor) typeOf(expr ast.Expr) types.Type {
+	if tv, ok := e.info.Types[expr]; ok {
+		return e.concrete(tv.Type)
+	}
+	return nil
+}
+
+// toJSONSafe converts values that can't be JSON-marshaled (e.g. map[interface{}]interface{},
+// complex numbers and non-finite floats) into JSON-safe equivalents
+func toJSONSafe(v interface{}) interface{} {
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		safe := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			safe[fmt.Sprintf("%v", k)] = toJSONSafe(v)
+		}
+		return safe
+	}
+
+	if f, ok := v.(*funcValue); ok {
+		// A function shows as its name
+		if f == nil {
+			return nil
+		}
+		return f.instance
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Struct:
+		// A struct's fields by name, as a map's entries are by key
+		safe := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			safe[rv.Type().Field(i).Name] = toJSONSafe(field(rv, i).Interface())
+		}
+		return safe
+	case reflect.Ptr:
+		// A pointer shows as Go prints it, as &{1 2}
+		if rv.IsNil() {
+			return nil
+		}
+		return fmt.Sprintf("%v", v)
+	case reflect.Float32, reflect.Float64:
+		// JSON has no NaN or infinities; show them as Go prints them
+		if f := rv.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprint(v)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(v)
+	case reflect.Slice, reflect.Array:
+		// Nested arrays and slices become nested JSON arrays, rows first.
+		// A []byte is converted too, which encoding/json would send as
+		// base64.
+		switch rv.Type().Elem().Kind() {
+		case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+			reflect.Slice, reflect.Array, reflect.Map, reflect.Interface, reflect.Uint8,
+			reflect.Struct, reflect.Ptr:
+			if rv.Kind() == reflect.Slice && rv.IsNil() {
+				return v
+			}
+			safe := make([]interface{}, rv.Len())
+			for i := range safe {
+				safe[i] = toJSONSafe(rv.Index(i).Interface())
+			}
+			return safe
+		}
+	}
+	return v
+}
+
+// display formats bytes and runes, and slices of them, with both their
+// number and their character, as in 97 'a'. It returns "" for other types,
+// which the value alone shows well enough.
+func display(typ string, v interface{}) string {
+	var char func(int64) string
+	switch strings.TrimPrefix(typ, "[]") {
+	case "byte":
+		char = func(c int64) string {
+			if c < utf8.RuneSelf {
+				return strconv.QuoteRune(rune(c))
+			}
+			// A byte of a multi-byte character isn't a character itself
+			return fmt.Sprintf(`'\x%02x'`, c)
+		}
+	case "rune":
+		char = func(c int64) string { return strconv.QuoteRune(rune(c)) }
+	default:
+		return ""
+	}
+
+	rv := reflect.ValueOf(v)
+	format := func(c reflect.Value) string {
+		var n int64
+		if c.Kind() == reflect.Uint8 {
+			n = int64(c.Uint())
+		} else {
+			n = c.Int()
+		}
+		return fmt.Sprintf("%d %s", n, char(n))
+	}
+	switch rv.Kind() {
+	case reflect.Uint8, reflect.Int32:
+		return format(rv)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 && rv.Type().Elem().Kind() != reflect.Int32 {
+			return ""
+		}
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			parts[i] = format(rv.Index(i))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// chromeEvent is one entry of the Chrome Trace Event Format, which Perfetto
+// and chrome://tracing can open
+type chromeEvent struct {
+	Name     string                 `json:"name"`
+	Category string                 `json:"cat,omitempty"`
+	Phase    string                 `json:"ph"`
+	Time     int                    `json:"ts"`
+	Duration int                    `json:"dur,omitempty"`
+	PID      int                    `json:"pid"`
+	TID      int                    `json:"tid"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents     []chromeEvent `json:"traceEvents"`
+	DisplayTimeUnit string        `json:"displayTimeUnit"`
+}
+
+// openCall is a function invocation whose slice hasn't ended yet
+type openCall struct {
+	name string
+	step tracer.Step
+}
+
+// ChromeTrace converts a trace into Chrome Trace Event Format JSON. Each
+// function invocation becomes a duration slice nested by call stack, with
+// the step index as the clock: one step is one microsecond.
+func ChromeTrace(steps []tracer.Step) ([]byte, error) {
+	out := chromeTrace{
+		TraceEvents: []chromeEvent{
+			{Name: "process_name", Phase: "M", PID: 1, Args: map[string]interface{}{"name": "goflow"}},
+		},
+		DisplayTimeUnit: "ns",
+	}
+
+	// Each goroutine gets its own track, named on its first step
+	open := make(map[int][]openCall)
+	var tids []int
+	closeFrom := func(tid, depth, now int) {
+		for len(open[tid]) > depth {
+			calls := open[tid]
+			call := calls[len(calls)-1]
+			open[tid] = calls[:len(calls)-1]
+			out.TraceEvents = append(out.TraceEvents, chromeEvent{
+				Name:     call.name,
+				Category: "function",
+				Phase:    "X",
+				Time:     call.step.StepIndex,
+				Duration: now - call.step.StepIndex,
+				PID:      1,
+				TID:      tid,
+				Args: map[string]interface{}{
+					"line":  call.step.Line,
+					"depth": len(open[tid]),
+				},
+			})
+		}
+	}
+
+	// A goroutine's calls end with its last step
+	end := make(map[int]int)
+	for _, step := range steps {
+		end[goroutineTID(step)] = step.StepIndex + 1
+	}
+
+	for _, step := range steps {
+		tid := goroutineTID(step)
+		if _, ok := open[tid]; !ok {
+			name := fmt.Sprintf("goroutine %d", tid)
+			if tid == 1 {
+				name += " (main)"
+			}
+			out.TraceEvents = append(out.TraceEvents, chromeEvent{
+				Name: "thread_name", Phase: "M", PID: 1, TID: tid,
+				Args: map[string]interface{}{"name": name},
+			})
+			open[tid] = nil
+			tids = append(tids, tid)
+		}
+
+		stack := step.CallStack
+		if len(stack) == 0 {
+			stack = []string{"main"}
+		}
+
+		// A func_enter starts a new invocation even when the previous one at
+		// the same depth had the same name, as in recursion
+		calls := open[tid]
+		keep := len(stack)
+		if step.StatementType == "func_enter" {
+			keep--
+		}
+		for i := 0; i < keep && i < len(calls); i++ {
+			if calls[i].name != stack[i] {
+				keep = i
+				break
+			}
+		}
+		closeFrom(tid, keep, step.StepIndex)
+
+		for i := len(open[tid]); i < len(stack); i++ {
+			open[tid] = append(open[tid], openCall{name: stack[i], step: step})
+		}
+	}
+	for _, tid := range tids {
+		closeFrom(tid, 0, end[tid])
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("chrome trace export error: %w", err)
+	}
+	return data, nil
+}
+
+// goroutineTID is the track of the goroutine that ran step. The interpreter
+// runs everything on the main goroutine.
+func goroutineTID(step tracer.Step) int {
+	if step.Goroutine == 0 {
+		return 1
+	}
+	return step.Goroutine
+}
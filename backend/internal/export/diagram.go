@@ -0,0 +1,87 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// Mermaid renders the program as Mermaid flowchart text. steps may be nil;
+// when given, loops and branches are annotated with how often they ran.
+func Mermaid(result *tracer.ASTResult, steps []tracer.Step) string {
+	chart := buildFlowChart(result, steps)
+
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	for _, fn := range chart.funcs {
+		fmt.Fprintf(&sb, "    subgraph %s[%s]\n", fn.id, mermaidText(fn.label))
+		for _, n := range fn.nodes {
+			var open, close string
+			switch n.shape {
+			case shapeDecision:
+				open, close = "{", "}"
+			case shapeTerminal:
+				open, close = "([", "])"
+			default:
+				open, close = "[", "]"
+			}
+			fmt.Fprintf(&sb, "        %s%s%s%s\n", n.id, open, mermaidText(n.label), close)
+		}
+		sb.WriteString("    end\n")
+	}
+	for _, e := range chart.edges {
+		if e.label != "" {
+			fmt.Fprintf(&sb, "    %s -->|%s| %s\n", e.from, mermaidText(e.label), e.to)
+		} else {
+			fmt.Fprintf(&sb, "    %s --> %s\n", e.from, e.to)
+		}
+	}
+	return sb.String()
+}
+
+// mermaidText quotes a label, using Mermaid's entity for embedded quotes
+func mermaidText(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// DOT renders the program as a Graphviz digraph. steps may be nil; when
+// given, loops and branches are annotated with how often they ran.
+func DOT(result *tracer.ASTResult, steps []tracer.Step) string {
+	chart := buildFlowChart(result, steps)
+
+	var sb strings.Builder
+	sb.WriteString("digraph goflow {\n")
+	sb.WriteString("    node [fontname=\"Helvetica\", fontsize=11];\n")
+	sb.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, fn := range chart.funcs {
+		fmt.Fprintf(&sb, "    subgraph cluster_%s {\n", fn.id)
+		fmt.Fprintf(&sb, "        label=%s;\n", dotText(fn.label))
+		for _, n := range fn.nodes {
+			attrs := "shape=box"
+			switch n.shape {
+			case shapeDecision:
+				attrs = "shape=diamond"
+			case shapeTerminal:
+				attrs = "shape=oval, style=filled, fillcolor=\"#e8e8e8\""
+			}
+			fmt.Fprintf(&sb, "        %s [label=%s, %s];\n", n.id, dotText(n.label), attrs)
+		}
+		sb.WriteString("    }\n")
+	}
+	for _, e := range chart.edges {
+		if e.label != "" {
+			fmt.Fprintf(&sb, "    %s -> %s [label=%s];\n", e.from, e.to, dotText(e.label))
+		} else {
+			fmt.Fprintf(&sb, "    %s -> %s;\n", e.from, e.to)
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotText quotes a label as a DOT string
+func dotText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
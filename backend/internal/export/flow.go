@@ -0,0 +1,317 @@
+// Package export renders traces into formats that can be used outside the
+// web app, such as diagram sources for documentation.
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// shape is how a flowchart node is drawn
+type shape int
+
+const (
+	shapeBox      shape = iota // plain statement
+	shapeDecision              // if, switch and loop headers
+	shapeTerminal              // function entry and exit
+)
+
+type flowNode struct {
+	id    string
+	label string
+	shape shape
+}
+
+type flowEdge struct {
+	from, to string
+	label    string
+}
+
+// flowFunc is one function's part of the chart, drawn as a cluster
+type flowFunc struct {
+	id    string
+	label string
+	nodes []flowNode
+}
+
+// flowChart is a control-flow chart built from the visualization AST
+type flowChart struct {
+	funcs []*flowFunc
+	edges []flowEdge
+}
+
+// exit is a dangling edge that still needs a target
+type exit struct {
+	from, label string
+}
+
+// loopContext collects where break and continue lead inside a loop or,
+// for break only, a switch. Contexts are chained to the enclosing ones so
+// labeled jumps can reach them.
+type loopContext struct {
+	id     string
+	label  string
+	isLoop bool
+	breaks []exit
+	outer  *loopContext
+}
+
+// find returns the innermost context a break (or, with loopOnly, a
+// continue) to label leads to; an empty label matches any
+func (c *loopContext) find(label string, loopOnly bool) *loopContext {
+	for ; c != nil; c = c.outer {
+		if label != "" && c.label == label || label == "" && (c.isLoop || !loopOnly) {
+			return c
+		}
+	}
+	return nil
+}
+
+type flowBuilder struct {
+	chart  *flowChart
+	fn     *flowFunc
+	counts *traceCounts
+	// labels maps the labels of the current function to their nodes
+	labels map[string]string
+}
+
+// buildFlowChart lays out each function's statements as a flowchart. With
+// a trace, loops are annotated with iteration counts and branch edges with
+// how often they were taken.
+func buildFlowChart(result *tracer.ASTResult, steps []tracer.Step) *flowChart {
+	b := &flowBuilder{chart: &flowChart{}}
+	if steps != nil {
+		b.counts = countTrace(steps)
+	}
+
+	for _, fn := range result.Nodes {
+		b.fn = &flowFunc{id: fn.ID, label: fn.Label}
+		b.chart.funcs = append(b.chart.funcs, b.fn)
+		b.labels = make(map[string]string)
+		collectLabels(fn, b.labels)
+
+		start := fn.ID + "_start"
+		end := fn.ID + "_end"
+		b.addNode(start, "start", shapeTerminal)
+		exits := b.block(fn.Children, []exit{{from: start}}, nil)
+		b.addNode(end, "end", shapeTerminal)
+		b.connect(exits, end)
+
+		// Returns jump straight to the end of the function
+		for _, id := range b.returns(fn) {
+			b.chart.edges = append(b.chart.edges, flowEdge{from: id, to: end})
+		}
+	}
+	return b.chart
+}
+
+func (b *flowBuilder) addNode(id, label string, s shape) {
+	b.fn.nodes = append(b.fn.nodes, flowNode{id: id, label: label, shape: s})
+}
+
+func (b *flowBuilder) connect(exits []exit, to string) {
+	for _, e := range exits {
+		b.chart.edges = append(b.chart.edges, flowEdge{from: e.from, to: to, label: e.label})
+	}
+}
+
+// block chains statements one after another and returns the exits of the last
+func (b *flowBuilder) block(nodes []*tracer.ASTNode, in []exit, loop *loopContext) []exit {
+	for _, node := range nodes {
+		in = b.statement(node, in, loop)
+	}
+	return in
+}
+
+func (b *flowBuilder) statement(node *tracer.ASTNode, in []exit, loop *loopContext) []exit {
+	switch node.Type {
+	case "for":
+		return b.loop(node, in, loop)
+	case "if":
+		return b.ifElse(node, in, loop)
+	case "switch":
+		return b.switchCases(node, in, loop)
+	}
+
+	b.addNode(node.ID, node.Label, shapeBox)
+	b.connect(in, node.ID)
+
+	if isReturn(node) {
+		return nil
+	}
+	switch branchKind(node) {
+	case "continue":
+		if ctx := loop.find(node.Target, true); ctx != nil {
+			b.chart.edges = append(b.chart.edges, flowEdge{from: node.ID, to: ctx.id})
+			return nil
+		}
+	case "break":
+		if ctx := loop.find(node.Target, false); ctx != nil {
+			ctx.breaks = append(ctx.breaks, exit{from: node.ID})
+			return nil
+		}
+	case "goto":
+		if to, ok := b.labels[node.Target]; ok {
+			b.chart.edges = append(b.chart.edges, flowEdge{from: node.ID, to: to})
+			return nil
+		}
+	}
+	return []exit{{from: node.ID}}
+}
+
+// branchKind returns break, continue or goto for a branch statement node.
+// These are keywords, so no other statement's label starts with them.
+func branchKind(node *tracer.ASTNode) string {
+	kind, _, _ := strings.Cut(node.Label, " ")
+	switch kind {
+	case "break", "continue", "goto":
+		return kind
+	}
+	return ""
+}
+
+// collectLabels maps the labeled statements under node to their node IDs
+func collectLabels(node *tracer.ASTNode, labels map[string]string) {
+	for _, child := range node.Children {
+		if child.GoLabel != "" {
+			labels[child.GoLabel] = child.ID
+		}
+		collectLabels(child, labels)
+	}
+}
+
+func (b *flowBuilder) loop(node *tracer.ASTNode, in []exit, outer *loopContext) []exit {
+	label := node.Label
+	if b.counts != nil {
+		label += fmt.Sprintf(" (%s)", plural(b.counts.iterations[node.StartLine], "iteration"))
+	}
+	b.addNode(node.ID, label, shapeDecision)
+	b.connect(in, node.ID)
+
+	ctx := &loopContext{id: node.ID, label: node.GoLabel, isLoop: true, outer: outer}
+	body := b.block(node.Children, []exit{{from: node.ID, label: "next"}}, ctx)
+	b.connect(body, node.ID)
+
+	return append([]exit{{from: node.ID, label: "done"}}, ctx.breaks...)
+}
+
+func (b *flowBuilder) ifElse(node *tracer.ASTNode, in []exit, loop *loopContext) []exit {
+	b.addNode(node.ID, node.Label, shapeDecision)
+	b.connect(in, node.ID)
+
+	var then []*tracer.ASTNode
+	var elseNode *tracer.ASTNode
+	for _, child := range node.Children {
+		if child.Type == "else" {
+			elseNode = child
+		} else {
+			then = append(then, child)
+		}
+	}
+
+	trueLabel, falseLabel := "true", "false"
+	if b.counts != nil {
+		branch := b.counts.conditions[node.StartLine]
+		trueLabel = fmt.Sprintf("true ×%d", branch.taken)
+		falseLabel = fmt.Sprintf("false ×%d", branch.notTaken)
+	}
+
+	exits := b.block(then, []exit{{from: node.ID, label: trueLabel}}, loop)
+	if elseNode != nil {
+		return append(exits, b.block(elseNode.Children, []exit{{from: node.ID, label: falseLabel}}, loop)...)
+	}
+	return append(exits, exit{from: node.ID, label: falseLabel})
+}
+
+func (b *flowBuilder) switchCases(node *tracer.ASTNode, in []exit, loop *loopContext) []exit {
+	b.addNode(node.ID, node.Label, shapeDecision)
+	b.connect(in, node.ID)
+
+	// break inside a case leaves the switch
+	ctx := &loopContext{id: node.ID, label: node.GoLabel, outer: loop}
+	var exits []exit
+	hasDefault := false
+	for _, c := range node.Children {
+		label := fmt.Sprintf("%s (line %d)", c.Label, c.StartLine)
+		if c.Label == "default" {
+			label = "default"
+			hasDefault = true
+		}
+		if b.counts != nil {
+			label += fmt.Sprintf(" ×%d", b.counts.cases[c.StartLine])
+		}
+		exits = append(exits, b.block(c.Children, []exit{{from: node.ID, label: label}}, ctx)...)
+	}
+	if !hasDefault {
+		exits = append(exits, exit{from: node.ID, label: "no match"})
+	}
+	return append(exits, ctx.breaks...)
+}
+
+// returns lists the return statements anywhere in a function body
+func (b *flowBuilder) returns(node *tracer.ASTNode) []string {
+	var ids []string
+	for _, child := range node.Children {
+		if isReturn(child) {
+			ids = append(ids, child.ID)
+		}
+		ids = append(ids, b.returns(child)...)
+	}
+	return ids
+}
+
+func isReturn(node *tracer.ASTNode) bool {
+	return node.Type == "statement" && node.Label == "return"
+}
+
+// branchCount is how often a condition was true and false
+type branchCount struct {
+	taken, notTaken int
+}
+
+// traceCounts aggregates a trace by source line
+type traceCounts struct {
+	iterations map[int]int
+	conditions map[int]branchCount
+	cases      map[int]int
+}
+
+func countTrace(steps []tracer.Step) *traceCounts {
+	c := &traceCounts{
+		iterations: make(map[int]int),
+		conditions: make(map[int]branchCount),
+		cases:      make(map[int]int),
+	}
+	for _, step := range steps {
+		switch step.StatementType {
+		case "for_cond":
+			// A loop check that failed ends the loop rather than starting an iteration
+			if step.ConditionResult == nil || *step.ConditionResult {
+				c.iterations[step.Line]++
+			}
+		case "if_cond":
+			if step.ConditionResult == nil {
+				continue
+			}
+			count := c.conditions[step.Line]
+			if *step.ConditionResult {
+				count.taken++
+			} else {
+				count.notTaken++
+			}
+			c.conditions[step.Line] = count
+		case "case_match":
+			c.cases[step.Line]++
+		}
+	}
+	return c
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,32 @@
+package export
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/goflow/visualizer/internal/api"
+)
+
+//go:embed templates/player.html
+var templates embed.FS
+
+var playerTemplate = template.Must(template.ParseFS(templates, "templates/player.html"))
+
+// HTML writes a self-contained page that replays the trace offline: the
+// response is embedded as JSON next to a small player with no external
+// scripts, styles or fonts
+func HTML(w io.Writer, resp *api.TraceResponse, title string) error {
+	if title == "" {
+		title = "GoFlow trace"
+	}
+	err := playerTemplate.Execute(w, struct {
+		Title    string
+		Response *api.TraceResponse
+	}{title, resp})
+	if err != nil {
+		return fmt.Errorf("html export error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func text(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// series returns the sample lines of the text format
+func series(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestCounterLabelCardinality(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		incs   [][]string
+		want   []string
+	}{
+		{
+			name: "no labels",
+			incs: [][]string{{}, {}, {}},
+			want: []string{"requests_total 3"},
+		},
+		{
+			name:   "one series per label value",
+			labels: []string{"route"},
+			incs:   [][]string{{"/trace"}, {"/share/{id}"}, {"/trace"}},
+			want:   []string{`requests_total{route="/share/{id}"} 1`, `requests_total{route="/trace"} 2`},
+		},
+		{
+			name:   "one series per combination",
+			labels: []string{"route", "code"},
+			incs:   [][]string{{"/trace", "200"}, {"/trace", "429"}, {"/trace", "200"}, {"/share", "200"}},
+			want: []string{
+				`requests_total{route="/share",code="200"} 1`,
+				`requests_total{route="/trace",code="200"} 2`,
+				`requests_total{route="/trace",code="429"} 1`,
+			},
+		},
+		{
+			name:   "values are escaped",
+			labels: []string{"error"},
+			incs:   [][]string{{"say \"hi\"\n"}},
+			want:   []string{`requests_total{error="say \"hi\"\n"} 1`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			c := r.NewCounter("requests_total", "Requests.", tt.labels...)
+			for _, values := range tt.incs {
+				c.Inc(values...)
+			}
+			got := series(text(t, r))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("series:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+	}{
+		{"too few", []string{"/trace"}},
+		{"too many", []string{"/trace", "200", "extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewRegistry().NewCounter("requests_total", "Requests.", "route", "code")
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			c.Inc(tt.values...)
+		})
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("duration_seconds", "Durations.", []float64{0.1, 1}, "mode")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "interpreter")
+	}
+	h.Observe(0.2, "native")
+
+	want := []string{
+		`duration_seconds_bucket{mode="interpreter",le="0.1"} 2`,
+		`duration_seconds_bucket{mode="interpreter",le="1"} 3`,
+		`duration_seconds_bucket{mode="interpreter",le="+Inf"} 4`,
+		`duration_seconds_sum{mode="interpreter"} 3.65`,
+		`duration_seconds_count{mode="interpreter"} 4`,
+		`duration_seconds_bucket{mode="native",le="0.1"} 0`,
+		`duration_seconds_bucket{mode="native",le="1"} 1`,
+		`duration_seconds_bucket{mode="native",le="+Inf"} 1`,
+		`duration_seconds_sum{mode="native"} 0.2`,
+		`duration_seconds_count{mode="native"} 1`,
+	}
+	if got := series(text(t, r)); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("series:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFuncMetricsAndHeaders(t *testing.T) {
+	r := NewRegistry()
+	inFlight := 2.0
+	r.NewGaugeFunc("in_flight", "Programs\nrunning.", func() float64 { return inFlight })
+	r.NewCounterFunc("hits_total", "Hits.", func() float64 { return 7 })
+	inFlight = 3
+
+	want := `# HELP in_flight Programs running.
+# TYPE in_flight gauge
+in_flight 3
+# HELP hits_total Hits.
+# TYPE hits_total counter
+hits_total 7
+`
+	if got := text(t, r); got != want {
+		t.Errorf("text:\n%s\nwant:\n%s", got, want)
+	}
+}
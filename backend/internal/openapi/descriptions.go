@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"github.com/goflow/visualizer/internal/api"
+	"github.com/goflow/visualizer/internal/tracer"
+)
+
+// enums restricts string fields, keyed by "Type.jsonName". The values come
+// from the lists the executors and server themselves use.
+var enums = map[string]interface{}{
+	"Step.statementType": tracer.StatementTypes,
+	"ASTNode.type":       tracer.NodeTypes,
+	"TraceRequest.mode":  api.Modes,
+	"Error.code":         api.Codes,
+	"Error.phase":        api.Phases,
+}
+
+// descriptions documents the types, keyed by type name, and their fields,
+// keyed by "Type.jsonName". Generation fails when one is missing.
+var descriptions = map[string]string{
+	"TraceRequest":      "A program to trace.",
+	"TraceRequest.code": "Complete Go source of package main.",
+	"TraceRequest.mode": "Execution backend. Empty means the server's default mode.",
+
+	"TraceResponse":             "The trace of a program.",
+	"TraceResponse.success":     "Whether the program parsed and ran.",
+	"TraceResponse.error":       "Failure message. Only sent by the unversioned endpoints; /api/v1 sends an ErrorResponse instead.",
+	"TraceResponse.sourceCode":  "The traced source code.",
+	"TraceResponse.totalSteps":  "Number of steps in trace.",
+	"TraceResponse.ast":         "Structure of the program for visualization.",
+	"TraceResponse.trace":       "Execution steps in order.",
+	"TraceResponse.finalOutput": "Everything the program printed.",
+
+	"ASTResult":       "Top-level declarations of the program.",
+	"ASTResult.nodes": "One node per function, with its statements as children.",
+
+	"ASTNode":           "A node of the program structure shown in the visualizer.",
+	"ASTNode.id":        "Unique node identifier.",
+	"ASTNode.type":      "Kind of node.",
+	"ASTNode.label":     "Display label, usually the source text.",
+	"ASTNode.startLine": "First line of the node (1-based).",
+	"ASTNode.endLine":   "Last line of the node (1-based).",
+	"ASTNode.children":  "Nested nodes, such as a loop body.",
+	"ASTNode.parentId":  "ID of the enclosing node; empty for functions.",
+	"ASTNode.goLabel":   "Label of a labeled statement, such as outer for outer: for ...",
+	"ASTNode.target":    "Label a break, continue or goto statement jumps to.",
+
+	"Step":                 "A single execution step.",
+	"Step.stepIndex":       "Sequential step number (0-based).",
+	"Step.line":            "Line in the source code (1-based).",
+	"Step.column":          "Column in the source code (1-based).",
+	"Step.statement":       "Source text or a short description of the step.",
+	"Step.statementType":   "Kind of step.",
+	"Step.variables":       "Snapshot of the variables in scope after the step.",
+	"Step.scopeStack":      "Blocks from outermost to innermost, such as [\"main\", \"for_1\", \"body\", \"if_2\"].",
+	"Step.output":          "Console output produced by the step.",
+	"Step.loopIteration":   "Iteration of the innermost loop, when inside one.",
+	"Step.callStack":       "Functions being executed, outermost first.",
+	"Step.functionName":    "Function the step belongs to; init while package-level variables are initialized.",
+	"Step.conditionResult": "Value of the condition for if_cond, for_cond and case_match steps.",
+	"Step.goroutine":       "Runtime id of the goroutine that executed the step; native mode only.",
+
+	"Variable":         "A variable's value at a step.",
+	"Variable.name":    "Variable name.",
+	"Variable.type":    "Go type, such as int or []string.",
+	"Variable.value":   "Current value as JSON.",
+	"Variable.display": "The value with its characters, for bytes, runes and slices of them, such as 97 'a'.",
+	"Variable.scope":   "Block that declares the variable, such as main or main.for_1.body.if_2, or package for globals. Shadowed variables are left out.",
+	"Variable.len":     "Length, for slices.",
+	"Variable.cap":     "Capacity, for slices.",
+	"Variable.backing": "Label, such as #1, shared by the slices and arrays whose elements are in the same memory, so writing through one changes the others.",
+
+	"LoopIteration":           "Position within a loop.",
+	"LoopIteration.loopId":    "Loop identifier, such as for_1 or range_2.",
+	"LoopIteration.iteration": "Iteration number (0-based).",
+
+	"ShareRequest":     "A program to save under a short ID, with the TraceRequest fields.",
+	"ShareRequest.ttl": "How long the link stays valid, as a Go duration such as 720h. Empty means the server's default.",
+
+	"ShareResponse":           "A saved program.",
+	"ShareResponse.id":        "Short ID of the program.",
+	"ShareResponse.url":       "Path that returns the program's trace.",
+	"ShareResponse.expiresAt": "When the link stops working; absent when it never expires.",
+
+	"ErrorResponse":         "Body of every failed /api/v1 request.",
+	"ErrorResponse.success": "Always false.",
+	"ErrorResponse.error":   "What went wrong.",
+
+	"Error":             "An API error.",
+	"Error.code":        "Stable identifier clients can switch on.",
+	"Error.message":     "Human-readable description.",
+	"Error.line":        "Line of the first diagnostic (1-based).",
+	"Error.column":      "Column of the first diagnostic (1-based).",
+	"Error.phase":       "Stage in which the program failed; absent for errors in the request itself.",
+	"Error.diagnostics": "Individual problems reported by the parser or compiler.",
+
+	"Diagnostic":         "One problem in the program.",
+	"Diagnostic.message": "Description of the problem.",
+	"Diagnostic.line":    "Line (1-based).",
+	"Diagnostic.column":  "Column (1-based).",
+
+	"ServerStats":            "Server statistics.",
+	"ServerStats.executions": "Execution slots.",
+	"ServerStats.rateLimit":  "Per-client rate limiter; absent when rate limiting is off.",
+	"ServerStats.cache":      "Trace cache; absent when caching is off.",
+
+	"SemaphoreStats":          "Concurrent execution slots.",
+	"SemaphoreStats.limit":    "Programs allowed to execute at once.",
+	"SemaphoreStats.inFlight": "Programs executing right now.",
+	"SemaphoreStats.rejected": "Requests turned away for lack of a slot.",
+
+	"LimiterStats":          "Per-client token bucket rate limiter.",
+	"LimiterStats.rate":     "Requests per second allowed on average.",
+	"LimiterStats.burst":    "Requests allowed in a burst.",
+	"LimiterStats.clients":  "Clients with a partly used bucket.",
+	"LimiterStats.allowed":  "Requests allowed.",
+	"LimiterStats.rejected": "Requests rejected.",
+
+	"Stats":           "In-memory trace cache.",
+	"Stats.entries":   "Traces in the cache.",
+	"Stats.bytes":     "Bytes of traces in the cache.",
+	"Stats.budget":    "Byte budget of the cache.",
+	"Stats.hits":      "Cache hits.",
+	"Stats.misses":    "Cache misses.",
+	"Stats.evictions": "Traces evicted to stay within the budget.",
+}
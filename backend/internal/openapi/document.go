@@ -0,0 +1,183 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/goflow/visualizer/internal/api"
+)
+
+// Version of the HTTP API the document describes
+const Version = "1"
+
+// TraceSchema returns the JSON Schema of a trace: the body of a successful
+// trace request
+func TraceSchema() ([]byte, error) {
+	g := newGenerator("#/$defs/")
+	g.schema(reflect.TypeOf(api.TraceResponse{}))
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+
+	doc := Schema{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "GoFlow execution trace",
+		"description": "Generated from the Go types by `goflow schema`; do not edit.",
+		"$ref":        "#/$defs/TraceResponse",
+		"$defs":       g.defs,
+	}
+	return marshal(doc)
+}
+
+// Document returns the OpenAPI 3.1 document of the HTTP API
+func Document() ([]byte, error) {
+	g := newGenerator("#/components/schemas/")
+	ref := func(v interface{}) Schema {
+		return g.schema(reflect.TypeOf(v))
+	}
+	body := func(v interface{}) Schema {
+		return Schema{
+			"required": true,
+			"content":  Schema{"application/json": Schema{"schema": ref(v)}},
+		}
+	}
+	jsonResponse := func(description string, schema Schema) Schema {
+		return Schema{
+			"description": description,
+			"content":     Schema{"application/json": Schema{"schema": schema}},
+		}
+	}
+	errorResponses := func(statuses ...int) Schema {
+		responses := Schema{}
+		for _, status := range statuses {
+			responses[strconv.Itoa(status)] = Schema{"$ref": "#/components/responses/Error"}
+		}
+		return responses
+	}
+	with := func(responses Schema, more Schema) Schema {
+		for k, v := range more {
+			responses[k] = v
+		}
+		return responses
+	}
+
+	trace := Schema{
+		"summary":     "Trace a program",
+		"operationId": "trace",
+		"parameters": []Schema{{
+			"name":        "format",
+			"in":          "query",
+			"description": "Output format: the trace as JSON, a Mermaid or Graphviz flowchart, or Chrome Trace Event JSON.",
+			"schema":      Schema{"type": "string", "enum": []string{"json", "mermaid", "dot", "chrome"}, "default": "json"},
+		}},
+		"requestBody": body(api.TraceRequest{}),
+		"responses": with(errorResponses(400, 405, 413, 422, 429, 500), Schema{
+			"200": Schema{
+				"description": "The trace in the requested format. Interpreter traces carry an ETag.",
+				"content": Schema{
+					"application/json": Schema{"schema": Schema{"oneOf": []Schema{
+						ref(api.TraceResponse{}),
+						{"type": "object", "description": "Chrome Trace Event Format, for format=chrome."},
+					}}},
+					"text/plain":        Schema{"schema": Schema{"type": "string", "description": "Mermaid flowchart, for format=mermaid."}},
+					"text/vnd.graphviz": Schema{"schema": Schema{"type": "string", "description": "Graphviz digraph, for format=dot."}},
+				},
+			},
+			"304": Schema{"description": "The trace named in If-None-Match is still current."},
+		}),
+	}
+
+	share := Schema{
+		"summary":     "Save a program under a short ID",
+		"operationId": "share",
+		"requestBody": body(api.ShareRequest{}),
+		"responses": with(errorResponses(400, 404, 405, 413, 422, 429, 500), Schema{
+			"201": jsonResponse("The saved program.", ref(api.ShareResponse{})),
+		}),
+	}
+
+	shared := Schema{
+		"summary":     "Trace a shared program",
+		"operationId": "sharedTrace",
+		"parameters": []Schema{{
+			"name":     "id",
+			"in":       "path",
+			"required": true,
+			"schema":   Schema{"type": "string"},
+		}},
+		"responses": with(errorResponses(404, 405, 422, 429, 500), Schema{
+			"200": jsonResponse("The trace.", ref(api.TraceResponse{})),
+		}),
+	}
+
+	exportHTML := Schema{
+		"summary":     "Export a trace as an offline HTML player",
+		"operationId": "exportHTML",
+		"requestBody": body(api.TraceRequest{}),
+		"responses": with(errorResponses(400, 405, 413, 422, 429, 500), Schema{
+			"200": Schema{
+				"description": "A self-contained HTML page that replays the trace.",
+				"content":     Schema{"text/html": Schema{"schema": Schema{"type": "string"}}},
+			},
+		}),
+	}
+
+	doc := Schema{
+		"openapi": "3.1.0",
+		"info": Schema{
+			"title":       "GoFlow API",
+			"version":     Version,
+			"description": "Traces Go programs step by step. Generated from the Go types by `goflow schema`; do not edit.",
+		},
+		"paths": Schema{
+			"/api/v1/trace":        Schema{"post": trace},
+			"/api/v1/share":        Schema{"post": share},
+			"/api/v1/share/{id}":   Schema{"get": shared},
+			"/api/v1/export/html":  Schema{"post": exportHTML},
+			"/api/v1/metrics":      Schema{"get": getJSON("Server statistics", "metrics", ref(api.ServerStats{}))},
+			"/api/v1/openapi.json": Schema{"get": getJSON("This document", "openapi", Schema{"type": "object"})},
+			"/health":              Schema{"get": getJSON("Health check", "health", Schema{"type": "object", "properties": Schema{"status": Schema{"type": "string"}}})},
+			"/metrics": Schema{"get": Schema{
+				"summary":     "Prometheus metrics",
+				"operationId": "prometheus",
+				"responses": Schema{"200": Schema{
+					"description": "Metrics in Prometheus text format.",
+					"content":     Schema{"text/plain": Schema{"schema": Schema{"type": "string"}}},
+				}},
+			}},
+		},
+		"components": Schema{
+			"responses": Schema{
+				"Error": jsonResponse("The request failed; see error.code.", ref(api.ErrorResponse{})),
+			},
+		},
+	}
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	doc["components"].(Schema)["schemas"] = g.defs
+	return marshal(doc)
+}
+
+func getJSON(summary, operationID string, schema Schema) Schema {
+	return Schema{
+		"summary":     summary,
+		"operationId": operationID,
+		"responses": Schema{
+			"200": Schema{
+				"description": summary + ".",
+				"content":     Schema{"application/json": Schema{"schema": schema}},
+			},
+		},
+	}
+}
+
+func marshal(doc Schema) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("openapi error: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,158 @@
+// Package openapi generates the JSON Schema of a trace and the OpenAPI
+// document of the HTTP API from the Go types, so neither can drift from
+// what the server actually sends.
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema object. Maps marshal with sorted keys, so the
+// generated documents are byte-for-byte reproducible.
+type Schema map[string]interface{}
+
+// generator turns Go types into schemas, collecting every named struct it
+// meets as a definition that others refer to
+type generator struct {
+	refPrefix string
+	defs      map[string]Schema
+	missing   []string
+}
+
+func newGenerator(refPrefix string) *generator {
+	return &generator{refPrefix: refPrefix, defs: make(map[string]Schema)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t; named structs become references
+func (g *generator) schema(t reflect.Type) Schema {
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Ptr:
+		return g.schema(t.Elem())
+	case t.Kind() == reflect.Struct:
+		g.define(t)
+		return Schema{"$ref": g.refPrefix + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		// Any JSON value
+		return Schema{}
+	}
+	panic(fmt.Sprintf("openapi: no schema for %s", t))
+}
+
+// define adds the definition of struct type t unless it already has one
+func (g *generator) define(t reflect.Type) {
+	name := t.Name()
+	if _, ok := g.defs[name]; ok {
+		return
+	}
+	def := Schema{"type": "object"}
+	// Registered before the fields so recursive types such as ASTNode
+	// refer to themselves instead of recursing forever
+	g.defs[name] = def
+
+	if doc, ok := descriptions[name]; ok {
+		def["description"] = doc
+	} else {
+		g.missing = append(g.missing, name)
+	}
+
+	properties := Schema{}
+	var required []string
+	g.fields(t, properties, &required)
+	def["properties"] = properties
+	if len(required) > 0 {
+		def["required"] = required
+	}
+}
+
+// fields adds the JSON fields of struct t, including those of embedded
+// structs, the way encoding/json flattens them
+func (g *generator) fields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+		if !omitempty {
+			*required = append(*required, name)
+			// Nil pointers, slices and maps are sent as null
+			switch f.Type.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Map:
+				prop = nullable(prop)
+			}
+		}
+
+		key := t.Name() + "." + name
+		if values, ok := enums[key]; ok {
+			prop["enum"] = values
+		}
+		if doc, ok := descriptions[key]; ok {
+			if _, isRef := prop["$ref"]; isRef {
+				// Keywords beside $ref are allowed since JSON Schema 2020-12
+				prop = Schema{"$ref": prop["$ref"], "description": doc}
+			} else {
+				prop["description"] = doc
+			}
+		} else {
+			g.missing = append(g.missing, key)
+		}
+		properties[name] = prop
+	}
+}
+
+// nullable lets s also be null
+func nullable(s Schema) Schema {
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+		return s
+	}
+	return Schema{"anyOf": []Schema{s, {"type": "null"}}}
+}
+
+// check reports the types and fields that have no description, so a field
+// added to the API can't slip through undocumented
+func (g *generator) check() error {
+	if len(g.missing) == 0 {
+		return nil
+	}
+	sort.Strings(g.missing)
+	return fmt.Errorf("openapi error: no description for %s", strings.Join(g.missing, ", "))
+}
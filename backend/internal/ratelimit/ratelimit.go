@@ -0,0 +1,162 @@
+// Package ratelimit bounds how often each client may call the API and how
+// many programs run at once.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is one client's token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token-bucket rate limiter keyed by client
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*bucket
+	// lastSweep is when idle buckets were last dropped
+	lastSweep time.Time
+	now       func() time.Time
+
+	allowed, rejected uint64
+}
+
+// LimiterStats describes the limiter's decisions so far
+type LimiterStats struct {
+	Rate     float64 `json:"rate"`
+	Burst    int     `json:"burst"`
+	Clients  int     `json:"clients"`
+	Allowed  uint64  `json:"allowed"`
+	Rejected uint64  `json:"rejected"`
+}
+
+// NewLimiter allows each client rate requests per second on average, with
+// bursts of up to burst requests
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it returns
+// false and how long until the next token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		l.allowed++
+		return true, 0
+	}
+	l.rejected++
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, since a fresh bucket
+// would be identical. It runs at most once a minute.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Stats returns a snapshot of the limiter's counters
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{
+		Rate:     l.rate,
+		Burst:    int(l.burst),
+		Clients:  len(l.buckets),
+		Allowed:  l.allowed,
+		Rejected: l.rejected,
+	}
+}
+
+// Semaphore caps the number of concurrent runs
+type Semaphore struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	rejected uint64
+}
+
+// SemaphoreStats describes current and past use of a Semaphore
+type SemaphoreStats struct {
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"inFlight"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// NewSemaphore allows up to n concurrent holders
+func NewSemaphore(n int) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+// Acquire waits for a free slot until ctx is done. It returns false if no
+// slot became free in time.
+func (s *Semaphore) Acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.rejected++
+		s.mu.Unlock()
+		return false
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (s *Semaphore) Release() {
+	<-s.slots
+}
+
+// Stats returns a snapshot of the semaphore's use
+func (s *Semaphore) Stats() SemaphoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SemaphoreStats{
+		Limit:    cap(s.slots),
+		InFlight: len(s.slots),
+		Rejected: s.rejected,
+	}
+}
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests move the limiter's time forward by hand
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	l := NewLimiter(rate, burst)
+	l.now = clock.now
+	l.lastSweep = clock.t
+	return l, clock
+}
+
+func TestLimiterRefillAndBurst(t *testing.T) {
+	// Each call advances the clock by wait, then calls Allow
+	type call struct {
+		wait    time.Duration
+		allowed bool
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		calls []call
+	}{
+		{
+			name:  "burst then empty",
+			rate:  1,
+			burst: 3,
+			calls: []call{{0, true}, {0, true}, {0, true}, {0, false}},
+		},
+		{
+			name:  "one token per interval",
+			rate:  2,
+			burst: 1,
+			calls: []call{{0, true}, {0, false}, {500 * time.Millisecond, true}, {250 * time.Millisecond, false}, {250 * time.Millisecond, true}},
+		},
+		{
+			name:  "refill is capped at burst",
+			rate:  10,
+			burst: 2,
+			calls: []call{{0, true}, {0, true}, {time.Hour, true}, {0, true}, {0, false}},
+		},
+		{
+			name:  "partial tokens accumulate",
+			rate:  1,
+			burst: 1,
+			calls: []call{{0, true}, {400 * time.Millisecond, false}, {400 * time.Millisecond, false}, {200 * time.Millisecond, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(tt.rate, tt.burst)
+			for i, c := range tt.calls {
+				clock.t = clock.t.Add(c.wait)
+				if allowed, _ := l.Allow("client"); allowed != c.allowed {
+					t.Fatalf("call %d: allowed = %v, want %v", i, allowed, c.allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{"empty bucket", 2, 0, 500 * time.Millisecond},
+		{"half refilled", 1, 500 * time.Millisecond, 500 * time.Millisecond},
+		{"slow rate", 0.1, 0, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(tt.rate, 1)
+			l.Allow("client")
+			clock.t = clock.t.Add(tt.elapsed)
+			allowed, wait := l.Allow("client")
+			if allowed {
+				t.Fatal("allowed with an empty bucket")
+			}
+			if diff := wait - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("wait = %v, want %v", wait, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("first request of a rejected")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("second request of a allowed")
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("b rejected because of a")
+	}
+
+	stats := l.Stats()
+	if stats.Allowed != 2 || stats.Rejected != 1 || stats.Clients != 2 {
+		t.Errorf("stats = %+v, want 2 allowed, 1 rejected, 2 clients", stats)
+	}
+}
+
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	l, clock := newTestLimiter(1, 5)
+	l.Allow("idle")
+	clock.t = clock.t.Add(2 * time.Minute)
+	l.Allow("active")
+	if got := l.Stats().Clients; got != 1 {
+		t.Errorf("clients = %d after sweep, want 1", got)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(2)
+	ctx := context.Background()
+	if !s.Acquire(ctx) || !s.Acquire(ctx) {
+		t.Fatal("free slots not acquired")
+	}
+
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	if s.Acquire(expired) {
+		t.Fatal("acquired a third slot")
+	}
+
+	s.Release()
+	if !s.Acquire(expired) {
+		t.Fatal("released slot not acquired")
+	}
+
+	want := SemaphoreStats{Limit: 2, InFlight: 2, Rejected: 1}
+	if got := s.Stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,249 @@
+// Package sandbox runs untrusted binaries in a separate process with CPU,
+// memory, file size and output limits and a wall-clock watchdog. On Linux the
+// process also gets its own user, network, mount and PID namespaces, runs as
+// an unprivileged user and sees a read-only filesystem. Where that isolation
+// is unavailable, Run refuses to start the process unless the configuration
+// allows a plain subprocess.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes the limits applied to a sandboxed process
+type Config struct {
+	Timeout      time.Duration // wall-clock limit enforced by the watchdog
+	CPUTime      time.Duration // RLIMIT_CPU
+	MemoryBytes  uint64        // RLIMIT_DATA; the Go runtime reserves too much address space for RLIMIT_AS
+	FileBytes    uint64        // RLIMIT_FSIZE, caps every file the process writes
+	OutputBytes  int64         // stdout size at which the watchdog kills the process
+	MaxOpenFiles uint64        // RLIMIT_NOFILE
+	Isolate      bool          // run in fresh user, network, mount and PID namespaces
+	// AllowUnisolated lets Run fall back to a plain subprocess, with network
+	// access and a writable filesystem, when Isolate is set but namespaces
+	// are unavailable. Without it such runs fail with an *IsolationError.
+	AllowUnisolated bool
+}
+
+// DefaultConfig returns limits suitable for short classroom programs
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      10 * time.Second,
+		CPUTime:      5 * time.Second,
+		MemoryBytes:  512 << 20,
+		FileBytes:    64 << 20,
+		OutputBytes:  1 << 20,
+		MaxOpenFiles: 64,
+		Isolate:      true,
+	}
+}
+
+// Process describes the program to run
+type Process struct {
+	Path       string
+	Args       []string
+	Dir        string
+	Env        []string
+	Stdout     *os.File // a regular file, so the watchdog can check its size
+	ExtraFiles []*os.File
+	// WritablePaths are the only places an isolated process may write; the
+	// rest of the filesystem is read-only. Descriptors opened before the
+	// process starts stay writable.
+	WritablePaths []string
+}
+
+// Result describes how a sandboxed process ended
+type Result struct {
+	ExitCode int
+	Stderr   string
+	Duration time.Duration
+	Isolated bool // namespaces were in effect
+	Limited  bool // rlimits were applied by the helper
+}
+
+// Limit names reported by LimitError
+const (
+	LimitTimeout = "timeout"
+	LimitCPU     = "cpu"
+	LimitMemory  = "memory"
+	LimitOutput  = "output"
+	LimitFile    = "file_size"
+)
+
+// LimitError reports that the sandbox stopped the process for exceeding a limit
+type LimitError struct {
+	Limit string
+	Value string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded (%s)", strings.ReplaceAll(e.Limit, "_", " "), e.Value)
+}
+
+// IsolationError reports that Config.Isolate was set but the process could
+// not be isolated, and Config.AllowUnisolated did not allow running it anyway
+type IsolationError struct {
+	Err error
+}
+
+func (e *IsolationError) Error() string {
+	return "sandbox isolation unavailable: " + e.Err.Error()
+}
+
+func (e *IsolationError) Unwrap() error {
+	return e.Err
+}
+
+// maxStderr bounds how much of the process's stderr is kept
+const maxStderr = 64 << 10
+
+// Run starts the process under cfg and waits for it to exit. A non-zero exit
+// is not an error; exceeding a limit returns a *LimitError alongside the result.
+func Run(ctx context.Context, cfg Config, p Process) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	stderr := &limitedBuffer{max: maxStderr}
+	newCmd := func(isolate bool) *exec.Cmd {
+		cmd := helperCommand(cfg, p, isolate)
+		cmd.Dir = p.Dir
+		cmd.Stdout = p.Stdout
+		cmd.Stderr = stderr
+		cmd.ExtraFiles = p.ExtraFiles
+		return cmd
+	}
+
+	isolate := cfg.Isolate
+	if isolate && !isolationSupported() {
+		if !cfg.AllowUnisolated {
+			return nil, &IsolationError{Err: errors.New("namespaces are not supported on this system")}
+		}
+		isolate = false
+	}
+	result := &Result{Isolated: isolate, Limited: helperAvailable()}
+	cmd := newCmd(isolate)
+	start := time.Now()
+	err := cmd.Start()
+	if err != nil && isolate && isNamespaceError(err) {
+		if !cfg.AllowUnisolated {
+			return nil, &IsolationError{Err: err}
+		}
+		// Explicitly allowed: run as a plain subprocess
+		result.Isolated = false
+		cmd = newCmd(false)
+		err = cmd.Start()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sandbox error: %w", err)
+	}
+
+	// Watchdog: kill on timeout or when stdout grows past the output limit
+	var mu sync.Mutex
+	var killedFor string
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				mu.Lock()
+				killedFor = LimitTimeout
+				mu.Unlock()
+				killProcess(cmd)
+				return
+			case <-ticker.C:
+				if cfg.OutputBytes <= 0 || p.Stdout == nil {
+					continue
+				}
+				if info, err := p.Stdout.Stat(); err == nil && info.Size() > cfg.OutputBytes {
+					mu.Lock()
+					killedFor = LimitOutput
+					mu.Unlock()
+					killProcess(cmd)
+					return
+				}
+			}
+		}
+	}()
+
+	waitErr := cmd.Wait()
+	close(done)
+	result.Duration = time.Since(start)
+	result.Stderr = stderr.String()
+
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return result, fmt.Errorf("sandbox error: %w", waitErr)
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	mu.Lock()
+	reason := killedFor
+	mu.Unlock()
+	switch reason {
+	case LimitTimeout:
+		return result, &LimitError{Limit: LimitTimeout, Value: cfg.Timeout.String()}
+	case LimitOutput:
+		return result, &LimitError{Limit: LimitOutput, Value: fmt.Sprintf("%d bytes", cfg.OutputBytes)}
+	}
+	if limit := limitFromExit(cmd.ProcessState, result.Stderr, cfg); limit != "" {
+		return result, &LimitError{Limit: limit, Value: cfg.limitValue(limit)}
+	}
+	if state := cmd.ProcessState; state != nil && !state.Exited() {
+		// Killed by a signal that no limit explains; say which, as go run does
+		result.Stderr += state.String() + "\n"
+	}
+
+	return result, nil
+}
+
+func (c Config) limitValue(limit string) string {
+	switch limit {
+	case LimitCPU:
+		return c.CPUTime.String()
+	case LimitMemory:
+		return fmt.Sprintf("%d bytes", c.MemoryBytes)
+	case LimitFile:
+		return fmt.Sprintf("%d bytes", c.FileBytes)
+	}
+	return ""
+}
+
+// limitedBuffer keeps the first max bytes written to it and drops the rest
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
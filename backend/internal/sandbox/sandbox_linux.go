@@ -0,0 +1,335 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The sandbox re-executes the current binary as a small helper that applies
+// rlimits and the read-only mounts from inside the child, then execs the
+// target. Go's os/exec has no way to set rlimits on a child directly.
+const (
+	helperEnv = "GOFLOW_SANDBOX_HELPER"
+	limitsEnv = "GOFLOW_SANDBOX_LIMITS"
+)
+
+// sandboxID is the uid and gid an isolated process has inside its user
+// namespace: nobody, mapped to the server's own ids outside it
+const sandboxID = 65534
+
+// Capabilities and prctl options not in package syscall
+const (
+	capSysAdmin          = 21
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+	prSetNoNewPrivs      = 38
+)
+
+var helperRegistered bool
+
+// Init must be called first thing in main of any binary that runs sandboxed
+// programs. In the parent it registers the helper; when the binary was started
+// as the helper it applies the limits and execs the target, never returning.
+func Init() {
+	if os.Getenv(helperEnv) == "" {
+		helperRegistered = true
+		return
+	}
+	if err := runHelper(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+}
+
+func helperAvailable() bool {
+	return helperRegistered
+}
+
+// isolationSupported reports whether isolated processes can be started: the
+// helper makes the filesystem read-only, so it must be registered
+func isolationSupported() bool {
+	return helperRegistered
+}
+
+func helperCommand(cfg Config, p Process, isolate bool) *exec.Cmd {
+	var cmd *exec.Cmd
+	self, err := os.Executable()
+	if helperRegistered && err == nil {
+		cmd = exec.Command(self, append([]string{p.Path}, p.Args...)...)
+		cmd.Env = append(append([]string{}, p.Env...),
+			helperEnv+"=1",
+			limitsEnv+"="+encodeLimits(cfg, p.WritablePaths, isolate),
+		)
+	} else {
+		// Without the helper only the watchdog and namespaces apply
+		cmd = exec.Command(p.Path, p.Args...)
+		cmd.Env = p.Env
+	}
+
+	attr := &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+	if isolate {
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+		// Inside the namespace the process is nobody, not root. The helper
+		// keeps CAP_SYS_ADMIN as an ambient capability for its mounts and
+		// drops it before running the target.
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: sandboxID, HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: sandboxID, HostID: os.Getgid(), Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+		attr.AmbientCaps = []uintptr{capSysAdmin}
+		// Pdeathsig is cleared by the uid change into the namespace
+		attr.Pdeathsig = 0
+	}
+	cmd.SysProcAttr = attr
+	return cmd
+}
+
+func encodeLimits(cfg Config, writable []string, isolate bool) string {
+	var parts []string
+	if secs := cpuSeconds(cfg); secs > 0 {
+		parts = append(parts, "cpu="+strconv.FormatUint(secs, 10))
+	}
+	if cfg.MemoryBytes > 0 {
+		parts = append(parts, "data="+strconv.FormatUint(cfg.MemoryBytes, 10))
+	}
+	if cfg.FileBytes > 0 {
+		parts = append(parts, "fsize="+strconv.FormatUint(cfg.FileBytes, 10))
+	}
+	if cfg.MaxOpenFiles > 0 {
+		parts = append(parts, "nofile="+strconv.FormatUint(cfg.MaxOpenFiles, 10))
+	}
+	if isolate {
+		parts = append(parts, "rw="+strings.Join(writable, ":"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// cpuSeconds is RLIMIT_CPU for cfg, which counts whole seconds
+func cpuSeconds(cfg Config) uint64 {
+	if cfg.CPUTime <= 0 {
+		return 0
+	}
+	if secs := uint64(cfg.CPUTime.Seconds()); secs > 0 {
+		return secs
+	}
+	return 1
+}
+
+var rlimitResources = map[string]int{
+	"cpu":    syscall.RLIMIT_CPU,
+	"data":   syscall.RLIMIT_DATA,
+	"fsize":  syscall.RLIMIT_FSIZE,
+	"nofile": syscall.RLIMIT_NOFILE,
+}
+
+func runHelper() error {
+	if len(os.Args) < 2 {
+		return errors.New("helper started without a target")
+	}
+
+	var writable []string
+	isolate := false
+	for _, part := range strings.Split(os.Getenv(limitsEnv), ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		if key == "rw" {
+			isolate = true
+			if value != "" {
+				writable = strings.Split(value, ":")
+			}
+			continue
+		}
+		resource, known := rlimitResources[key]
+		if !known {
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad limit %q: %w", part, err)
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: n, Max: n}); err != nil {
+			return fmt.Errorf("setrlimit %s: %w", key, err)
+		}
+	}
+
+	if isolate {
+		if err := readOnlyRoot(writable); err != nil {
+			return err
+		}
+		// The working directory was entered before the mounts; re-enter it
+		// so relative paths resolve through the read-only view
+		if wd, err := os.Getwd(); err == nil {
+			if err := os.Chdir(wd); err != nil {
+				return fmt.Errorf("chdir %s: %w", wd, err)
+			}
+		}
+		// The target runs as nobody without capabilities, and can't regain
+		// any through setuid or file-capability binaries
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("drop capabilities: %w", errno)
+		}
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("set no_new_privs: %w", errno)
+		}
+	}
+
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, helperEnv+"=") && !strings.HasPrefix(kv, limitsEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	return syscall.Exec(os.Args[1], os.Args[1:], env)
+}
+
+// readOnlyRoot makes every mount in the process's private mount namespace
+// read-only, except the writable paths, which are bind-mounted onto
+// themselves first so they stay writable
+func readOnlyRoot(writable []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	keep := make(map[string]bool)
+	for _, dir := range writable {
+		if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", dir, err)
+		}
+		keep[dir] = true
+	}
+
+	mounts, err := mountPoints()
+	if err != nil {
+		return err
+	}
+	for _, dir := range mounts {
+		if keep[dir] {
+			continue
+		}
+		err := remountReadOnly(dir)
+		if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.ENOENT) {
+			// Out of the process's reach, as it has the same credentials
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountPoints lists the mount points in /proc/self/mountinfo, parents
+// before the mounts below them
+func mountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("read mounts: %w", err)
+	}
+	defer f.Close()
+
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The fifth field is the mount point, with spaces and the like
+		// escaped as octal
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, unescapeMountPath(fields[4]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read mounts: %w", err)
+	}
+	return mounts, nil
+}
+
+func unescapeMountPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if n, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// remountReadOnly remounts the mount at dir read-only. A remount inside a
+// user namespace must keep the flags locked by the parent mount.
+func remountReadOnly(dir string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", dir, err)
+	}
+	const keep = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+	flags := uintptr(syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY) | uintptr(st.Flags)&keep
+	if err := syscall.Mount("", dir, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", dir, err)
+	}
+	return nil
+}
+
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	// Kill the whole process group, falling back to the process itself
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		cmd.Process.Kill()
+	}
+}
+
+func isNamespaceError(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EACCES)
+}
+
+// cpuSlack allows for the kernel accounting CPU time more coarsely than it
+// enforces RLIMIT_CPU
+const cpuSlack = 100 * time.Millisecond
+
+// limitFromExit maps the way the process died to the rlimit that killed it.
+// The kernel kills a process that reaches RLIMIT_CPU with SIGKILL, which
+// other causes send too, so it is only the CPU limit when the process used
+// that much CPU time.
+func limitFromExit(state *os.ProcessState, stderr string, cfg Config) string {
+	if state == nil {
+		return ""
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		switch ws.Signal() {
+		case syscall.SIGXCPU:
+			return LimitCPU
+		case syscall.SIGKILL:
+			limit := time.Duration(cpuSeconds(cfg)) * time.Second
+			if limit > 0 && state.UserTime()+state.SystemTime() >= limit-cpuSlack {
+				return LimitCPU
+			}
+		case syscall.SIGXFSZ:
+			return LimitFile
+		}
+	}
+	if strings.Contains(stderr, "out of memory") || strings.Contains(stderr, "cannot allocate memory") {
+		return LimitMemory
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Init is a no-op outside Linux, where processes can't be isolated: Run
+// refuses them unless Config.AllowUnisolated lets them run as plain
+// subprocesses bounded only by the watchdog's timeout and output limits.
+func Init() {}
+
+func helperAvailable() bool {
+	return false
+}
+
+func isolationSupported() bool {
+	return false
+}
+
+func helperCommand(cfg Config, p Process, isolate bool) *exec.Cmd {
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Env = p.Env
+	return cmd
+}
+
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+func isNamespaceError(err error) bool {
+	return false
+}
+
+func limitFromExit(state *os.ProcessState, stderr string, cfg Config) string {
+	return ""
+}
@@ -0,0 +1,87 @@
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps one JSON file per entry in a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates dir if needed and returns a store backed by it
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("share store error: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *FileStore) Put(e *Entry) error {
+	if !ValidID(e.ID) {
+		return fmt.Errorf("share store error: invalid id %q", e.ID)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("share store error: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never see a partial entry
+	tmp, err := os.CreateTemp(s.dir, e.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("share store error: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("share store error: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("share store error: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(e.ID)); err != nil {
+		return fmt.Errorf("share store error: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Get(id string) (*Entry, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("share store error: %w", err)
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("share store error: %s: %w", id, err)
+	}
+	return &e, nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	if !ValidID(id) {
+		return nil
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("share store error: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,203 @@
+package share
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// KVStore is an embedded key-value store in a single append-only log file.
+// An in-memory index maps each ID to the offset of its latest record; the
+// log is compacted when it is opened.
+type KVStore struct {
+	mu    sync.Mutex
+	file  *os.File
+	path  string
+	size  int64
+	index map[string]recordPos
+}
+
+type recordPos struct {
+	offset int64
+	length int
+}
+
+// kvRecord is one line of the log; a record without an entry deletes ID
+type kvRecord struct {
+	ID    string `json:"id"`
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+// OpenKVStore opens or creates the log at path
+func OpenKVStore(path string) (*KVStore, error) {
+	s := &KVStore{path: path, index: make(map[string]recordPos)}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("share store error: %w", err)
+	}
+	if err := s.compact(); err != nil {
+		return nil, fmt.Errorf("share store error: %w", err)
+	}
+	return s, nil
+}
+
+// load rebuilds the index, dropping a torn record left by a crash mid-write
+func (s *KVStore) load() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file = f
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec kvRecord
+		if json.Unmarshal(line, &rec) == nil && rec.ID != "" {
+			if rec.Entry != nil {
+				s.index[rec.ID] = recordPos{offset: offset, length: len(line)}
+			} else {
+				delete(s.index, rec.ID)
+			}
+		}
+		offset += int64(len(line))
+	}
+
+	s.size = offset
+	return f.Truncate(offset)
+}
+
+// compact rewrites the log with only live, unexpired entries
+func (s *KVStore) compact() error {
+	now := time.Now()
+	var buf bytes.Buffer
+	index := make(map[string]recordPos, len(s.index))
+	for id, pos := range s.index {
+		rec, err := s.read(pos)
+		if err != nil {
+			return err
+		}
+		if rec.Entry.Expired(now) {
+			continue
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		index[id] = recordPos{offset: int64(buf.Len()), length: len(line)}
+		buf.Write(line)
+	}
+	if int64(buf.Len()) == s.size {
+		return nil
+	}
+
+	tmp := s.path + ".compact"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = f
+	s.size = int64(buf.Len())
+	s.index = index
+	return nil
+}
+
+func (s *KVStore) read(pos recordPos) (*kvRecord, error) {
+	line := make([]byte, pos.length)
+	if _, err := s.file.ReadAt(line, pos.offset); err != nil {
+		return nil, err
+	}
+	var rec kvRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *KVStore) append(rec kvRecord) (recordPos, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return recordPos{}, err
+	}
+	line = append(line, '\n')
+	if _, err := s.file.WriteAt(line, s.size); err != nil {
+		return recordPos{}, err
+	}
+	if err := s.file.Sync(); err != nil {
+		return recordPos{}, err
+	}
+	pos := recordPos{offset: s.size, length: len(line)}
+	s.size += int64(len(line))
+	return pos, nil
+}
+
+func (s *KVStore) Put(e *Entry) error {
+	if !ValidID(e.ID) {
+		return fmt.Errorf("share store error: invalid id %q", e.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, err := s.append(kvRecord{ID: e.ID, Entry: e})
+	if err != nil {
+		return fmt.Errorf("share store error: %w", err)
+	}
+	s.index[e.ID] = pos
+	return nil
+}
+
+func (s *KVStore) Get(id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, ok := s.index[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rec, err := s.read(pos)
+	if err != nil {
+		return nil, fmt.Errorf("share store error: %w", err)
+	}
+	return rec.Entry, nil
+}
+
+func (s *KVStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[id]; !ok {
+		return nil
+	}
+	if _, err := s.append(kvRecord{ID: id}); err != nil {
+		return fmt.Errorf("share store error: %w", err)
+	}
+	delete(s.index, id)
+	return nil
+}
+
+func (s *KVStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
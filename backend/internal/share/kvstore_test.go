@@ -0,0 +1,165 @@
+package share
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goflow/visualizer/internal/api"
+)
+
+func newEntry(code string, expiresAt time.Time) *Entry {
+	req := api.TraceRequest{Code: code}
+	return &Entry{ID: ID(req), Request: req, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+}
+
+func TestEntryExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      bool
+	}{
+		{"never expires", time.Time{}, false},
+		{"in the future", now.Add(time.Minute), false},
+		{"exactly now", now, true},
+		{"in the past", now.Add(-time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Entry{ExpiresAt: tt.expiresAt}
+			if got := e.Expired(now); got != tt.want {
+				t.Errorf("Expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKVStoreExpiry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		// kept reports whether the entry survives reopening the store
+		kept bool
+	}{
+		{"no expiry", time.Time{}, true},
+		{"not yet expired", now.Add(time.Hour), true},
+		{"expired", now.Add(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shares.db")
+			s, err := OpenKVStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := newEntry("package main\n// "+tt.name, tt.expiresAt)
+			if err := s.Put(e); err != nil {
+				t.Fatal(err)
+			}
+			// Until the log is compacted, expiry is left to the caller
+			if _, err := s.Get(e.ID); err != nil {
+				t.Fatalf("Get before reopen: %v", err)
+			}
+			s.Close()
+
+			s, err = OpenKVStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			_, err = s.Get(e.ID)
+			if tt.kept && err != nil {
+				t.Errorf("Get after reopen: %v", err)
+			}
+			if !tt.kept && !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after reopen = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestKVStorePutGetDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shares.db")
+	s, err := OpenKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newEntry("package main\n// a", time.Time{})
+	b := newEntry("package main\n// b", time.Time{})
+	for _, e := range []*Entry{a, b} {
+		if err := s.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A second Put replaces the entry
+	a.Trace = []byte(`{"success":true}`)
+	a.Version = "2"
+	if err := s.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(b.ID); err != nil {
+		t.Errorf("deleting a missing entry: %v", err)
+	}
+	s.Close()
+
+	s, err = OpenKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, err := s.Get(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Trace) != string(a.Trace) || got.Version != "2" {
+		t.Errorf("Get = trace %s version %q, want the replacement", got.Trace, got.Version)
+	}
+	if _, err := s.Get(b.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of deleted entry = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(&Entry{ID: "../escape"}); err == nil {
+		t.Error("Put accepted an invalid id")
+	}
+}
+
+func TestKVStoreDropsTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shares.db")
+	s, err := OpenKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newEntry("package main", time.Time{})
+	if err := s.Put(e); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// A crash in the middle of the next write
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"AAAAAAAAAAA","entry":{"id"`)
+	f.Close()
+
+	s, err = OpenKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get(e.ID); err != nil {
+		t.Errorf("Get of intact entry: %v", err)
+	}
+	if _, err := s.Get("AAAAAAAAAAA"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of torn entry = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Package share persists shared programs so that a short link can bring back
+// the same code and trace later.
+package share
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/goflow/visualizer/internal/api"
+)
+
+// ErrNotFound is returned for unknown and expired IDs
+var ErrNotFound = errors.New("share not found")
+
+// Entry is a shared program: the request that produced it and, when it was
+// small enough to keep, the trace it produced
+type Entry struct {
+	ID        string           `json:"id"`
+	Request   api.TraceRequest `json:"request"`
+	CreatedAt time.Time        `json:"createdAt"`
+	// ExpiresAt is zero for entries that never expire
+	ExpiresAt time.Time       `json:"expiresAt,omitempty"`
+	Trace     json.RawMessage `json:"trace,omitempty"`
+	// Version is the executor.Version that produced Trace; a trace from
+	// another version is stale and the program is executed again
+	Version string `json:"version,omitempty"`
+}
+
+// Expired reports whether the entry is past its expiry time
+func (e *Entry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Store saves entries under their ID
+type Store interface {
+	// Put saves the entry, replacing any entry with the same ID
+	Put(e *Entry) error
+	// Get returns the entry, or ErrNotFound
+	Get(id string) (*Entry, error)
+	// Delete removes the entry; deleting a missing entry is not an error
+	Delete(id string) error
+	Close() error
+}
+
+// idLength is the number of base64url characters kept from the hash
+const idLength = 11
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// ID derives the short ID from the content of a request, so sharing the same
+// code with the same options always gives the same link
+func ID(req api.TraceRequest) string {
+	h := sha256.New()
+	h.Write([]byte(req.Mode))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Code))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))[:idLength]
+}
+
+// ValidID reports whether id has the shape of an ID returned by ID, which
+// also keeps IDs safe to use as file names
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
@@ -2,6 +2,7 @@ package tracer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -11,32 +12,38 @@ import (
 	"strings"
 )
 
-// InstrumentCode takes Go source code and instruments it with trace calls
+// ErrNotMain is returned for programs outside package main, which can't be run
+var ErrNotMain = errors.New("package must be main")
+
+// InstrumentCode takes Go source code and instruments it with trace calls.
+// The result must be compiled together with TraceRuntime, which provides the
+// __trace__ family of functions the instrumented code calls into.
 func InstrumentCode(code string) (string, error) {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "main.go", code, parser.ParseComments)
 	if err != nil {
 		return "", fmt.Errorf("parse error: %w", err)
 	}
+	if file.Name.Name != "main" {
+		return "", fmt.Errorf("instrument error: %w, got %s", ErrNotMain, file.Name.Name)
+	}
 
-	// Track variable declarations for each scope
 	instrumenter := &codeInstrumenter{
-		fset:       fset,
-		loopDepth:  0,
-		loopCount:  0,
-		scopeStack: []string{"main"},
-		variables:  make(map[string][]varInfo),
+		fset:      fset,
+		functions: make(map[string]bool),
 	}
 
-	// Add trace package import and runtime code
-	instrumenter.addTraceImport(file)
+	// Pre-scan: remember top-level functions so calls to them can be traced
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			instrumenter.functions[fn.Name.Name] = true
+		}
+	}
 
-	// Instrument function bodies
+	// Instrument every function body
 	for _, decl := range file.Decls {
-		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "main" {
-			if fn.Body != nil {
-				fn.Body.List = instrumenter.instrumentBlock(fn.Body.List, "main")
-			}
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			instrumenter.instrumentFunc(fn)
 		}
 	}
 
@@ -46,154 +53,315 @@ func InstrumentCode(code string) (string, error) {
 		return "", fmt.Errorf("print error: %w", err)
 	}
 
-	// Prepend the trace runtime code
-	result := getTraceRuntime() + "\n" + buf.String()
-
-	return result, nil
+	return buf.String(), nil
 }
 
 type varInfo struct {
-	name     string
-	typeName string
+	name  string
+	scope string
+}
+
+// blockScope is a lexical block of the instrumented program
+type blockScope struct {
+	name string
+	vars []varInfo
 }
 
 type codeInstrumenter struct {
-	fset       *token.FileSet
-	loopDepth  int
-	loopCount  int
-	scopeStack []string
-	variables  map[string][]varInfo // scope -> variables
+	fset      *token.FileSet
+	loopCount int
+	ifCount   int
+	blocks    []*blockScope
+	functions map[string]bool
+	results   *ast.FieldList // of the function being instrumented
 }
 
-func (c *codeInstrumenter) addTraceImport(file *ast.File) {
-	// Check if fmt is already imported
-	hasFmt := false
-	for _, imp := range file.Imports {
-		if imp.Path.Value == `"fmt"` {
-			hasFmt = true
-			break
+func (c *codeInstrumenter) instrumentFunc(fn *ast.FuncDecl) {
+	name := fn.Name.Name
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		name = receiverTypeName(fn.Recv.List[0].Type) + "." + name
+	}
+	c.blocks = nil
+	c.results = fn.Type.Results
+	c.pushScope(name)
+	defer c.popScope()
+
+	// Receiver, parameters and named results are visible in the whole body
+	for _, list := range []*ast.FieldList{fn.Recv, fn.Type.Params, fn.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			for _, ident := range field.Names {
+				c.addVariable(ident.Name)
+			}
 		}
 	}
 
-	if !hasFmt {
-		// Add fmt import
-		fmtImport := &ast.ImportSpec{
-			Path: &ast.BasicLit{
-				Kind:  token.STRING,
-				Value: `"fmt"`,
-			},
-		}
-		if len(file.Decls) > 0 {
-			if genDecl, ok := file.Decls[0].(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
-				genDecl.Specs = append(genDecl.Specs, fmtImport)
+	// A generic function is named after its instantiation, as Max[int],
+	// whose type arguments are only known when it runs
+	var nameArg ast.Expr = stringLit(name)
+	if fn.Type.TypeParams != nil {
+		args := []ast.Expr{nameArg}
+		for _, field := range fn.Type.TypeParams.List {
+			for _, ident := range field.Names {
+				// (*T)(nil)
+				args = append(args, &ast.CallExpr{
+					Fun:  &ast.ParenExpr{X: &ast.StarExpr{X: ast.NewIdent(ident.Name)}},
+					Args: []ast.Expr{ast.NewIdent("nil")},
+				})
 			}
 		}
+		nameArg = &ast.CallExpr{Fun: ast.NewIdent("__instance__"), Args: args}
+	} else if params := receiverTypeParams(fn); len(params) > 0 {
+		// A generic type's method is named after the receiver's
+		// instantiation, as Stack[int].Push
+		args := []ast.Expr{stringLit(receiverTypeName(fn.Recv.List[0].Type))}
+		for _, param := range params {
+			args = append(args, &ast.CallExpr{
+				Fun:  &ast.ParenExpr{X: &ast.StarExpr{X: ast.NewIdent(param.Name)}},
+				Args: []ast.Expr{ast.NewIdent("nil")},
+			})
+		}
+		nameArg = &ast.BinaryExpr{
+			X:  &ast.CallExpr{Fun: ast.NewIdent("__instance__"), Args: args},
+			Op: token.ADD,
+			Y:  stringLit("." + fn.Name.Name),
+		}
 	}
+
+	line := c.fset.Position(fn.Body.Pos()).Line
+	enter := c.traceCall("__enter__", line, nameArg, stringLit(c.currentScope()))
+	leave := &ast.DeferStmt{Call: &ast.CallExpr{Fun: ast.NewIdent("__leave__")}}
+
+	body := c.instrumentBlock(fn.Body.List)
+	fn.Body.List = append([]ast.Stmt{enter, leave}, body...)
 }
 
-func (c *codeInstrumenter) instrumentBlock(stmts []ast.Stmt, scope string) []ast.Stmt {
+func (c *codeInstrumenter) instrumentBlock(stmts []ast.Stmt) []ast.Stmt {
 	var result []ast.Stmt
 
 	for _, stmt := range stmts {
-		instrumented := c.instrumentStatement(stmt, scope)
+		instrumented := c.instrumentStatement(stmt)
 		result = append(result, instrumented...)
 	}
 
 	return result
 }
 
-func (c *codeInstrumenter) instrumentStatement(stmt ast.Stmt, scope string) []ast.Stmt {
+// instrumentBody instruments a loop body, which is a block inside the
+// loop's own: the body can shadow the loop variables
+func (c *codeInstrumenter) instrumentBody(stmts []ast.Stmt) []ast.Stmt {
+	c.pushScope("body")
+	defer c.popScope()
+	return c.instrumentBlock(stmts)
+}
+
+func (c *codeInstrumenter) instrumentStatement(stmt ast.Stmt) []ast.Stmt {
 	var result []ast.Stmt
 
 	switch s := stmt.(type) {
 	case *ast.AssignStmt:
-		// Track new variables from short declarations
+		result = append(result, c.funcCallTraces(s)...)
+		result = append(result, stmt)
+		// New variables are only visible after the statement
 		if s.Tok == token.DEFINE {
 			for _, lhs := range s.Lhs {
 				if ident, ok := lhs.(*ast.Ident); ok {
-					c.addVariable(scope, ident.Name, "auto")
+					c.addVariable(ident.Name)
 				}
 			}
 		}
-		result = append(result, stmt)
-		result = append(result, c.createTraceCall(s.Pos(), "assign", scope))
+		result = append(result, c.createTraceCall(s, "assign"))
 
 	case *ast.DeclStmt:
-		// Track variable declarations
-		if genDecl, ok := s.Decl.(*ast.GenDecl); ok && genDecl.Tok == token.VAR {
+		result = append(result, c.funcCallTraces(s)...)
+		result = append(result, stmt)
+		if genDecl, ok := s.Decl.(*ast.GenDecl); ok && (genDecl.Tok == token.VAR || genDecl.Tok == token.CONST) {
 			for _, spec := range genDecl.Specs {
 				if valueSpec, ok := spec.(*ast.ValueSpec); ok {
-					typeName := "auto"
-					if valueSpec.Type != nil {
-						typeName = c.getTypeName(valueSpec.Type)
-					}
 					for _, name := range valueSpec.Names {
-						c.addVariable(scope, name.Name, typeName)
+						c.addVariable(name.Name)
 					}
 				}
 			}
 		}
-		result = append(result, stmt)
-		result = append(result, c.createTraceCall(s.Pos(), "declare", scope))
+		result = append(result, c.createTraceCall(s, "declare"))
 
 	case *ast.ForStmt:
 		c.loopCount++
 		loopID := fmt.Sprintf("for_%d", c.loopCount)
-		c.loopDepth++
-		newScope := scope + "." + loopID
+		line := c.fset.Position(s.Pos()).Line
+
+		result = append(result, c.traceCall("__loopStart__", line,
+			stringLit("for loop start"), stringLit(c.currentScope()), stringLit(loopID)))
 
-		// Handle init statement
+		c.pushScope(loopID)
 		if s.Init != nil {
-			initStmts := c.instrumentStatement(s.Init, newScope)
-			if len(initStmts) > 0 {
-				s.Init = initStmts[0]
+			if assign, ok := s.Init.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+				for _, lhs := range assign.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok {
+						c.addVariable(ident.Name)
+					}
+				}
 			}
 		}
 
-		// Instrument loop body
-		if s.Body != nil {
-			// Add trace at start of each iteration
-			iterTrace := c.createTraceCallWithLoop(s.Body.Pos(), "for_cond", newScope, loopID)
-			instrumentedBody := c.instrumentBlock(s.Body.List, newScope)
-			s.Body.List = append([]ast.Stmt{iterTrace}, instrumentedBody...)
+		var iterTrace []ast.Stmt
+		if s.Cond != nil {
+			// Wrap the condition so every check, including the failing one, is recorded
+			s.Cond = c.condCall(line, "for_cond", "condition check", loopID, s.Cond)
+		} else {
+			iterTrace = append(iterTrace, c.traceCall("__loop__", line,
+				stringLit("condition check"), stringLit(c.currentScope()), stringLit(loopID)))
 		}
+		s.Body.List = append(iterTrace, c.instrumentBody(s.Body.List)...)
+		c.popScope()
+
+		result = append(result, s)
+
+	case *ast.RangeStmt:
+		c.loopCount++
+		loopID := fmt.Sprintf("range_%d", c.loopCount)
+		line := c.fset.Position(s.Pos()).Line
+
+		result = append(result, c.traceCall("__loopStart__", line,
+			stringLit("for range start"), stringLit(c.currentScope()), stringLit(loopID)))
+
+		c.pushScope(loopID)
+		if s.Tok == token.DEFINE {
+			for _, expr := range []ast.Expr{s.Key, s.Value} {
+				if ident, ok := expr.(*ast.Ident); ok {
+					c.addVariable(ident.Name)
+				}
+			}
+		}
+		iterTrace := c.traceCall("__loop__", line,
+			stringLit("range iteration"), stringLit(c.currentScope()), stringLit(loopID))
+		s.Body.List = append([]ast.Stmt{iterTrace}, c.instrumentBody(s.Body.List)...)
+		c.popScope()
 
-		c.loopDepth--
-		result = append(result, c.createTraceCall(s.Pos(), "for_init", scope))
 		result = append(result, s)
 
 	case *ast.IfStmt:
-		result = append(result, c.createTraceCall(s.Pos(), "if_cond", scope))
+		result = append(result, c.funcCallTraces(s.Init)...)
+		result = append(result, c.instrumentIf(s))
+
+	case *ast.SwitchStmt:
+		line := c.fset.Position(s.Pos()).Line
+		label := "switch"
+		if s.Tag != nil {
+			label = "switch " + c.exprText(s.Tag)
+		}
+
+		result = append(result, c.funcCallTraces(s.Init)...)
+		result = append(result, c.traceCall("__trace__", line,
+			stringLit("switch_tag"), stringLit(label), stringLit(c.currentScope())))
+
+		c.ifCount++
+		c.pushScope(fmt.Sprintf("switch_%d", c.ifCount))
+		if s.Init != nil {
+			c.declareInit(s.Init)
+		}
+		for _, stmt := range s.Body.List {
+			if cc, ok := stmt.(*ast.CaseClause); ok {
+				c.instrumentClause(cc.Pos(), caseLabel(c, cc.List), &cc.Body, nil)
+			}
+		}
+		c.popScope()
+		result = append(result, s)
 
-		// Instrument if body
-		if s.Body != nil {
-			s.Body.List = c.instrumentBlock(s.Body.List, scope)
+	case *ast.TypeSwitchStmt:
+		line := c.fset.Position(s.Pos()).Line
+		result = append(result, c.traceCall("__trace__", line,
+			stringLit("switch_tag"), stringLit("switch "+c.exprText(typeSwitchExpr(s.Assign))), stringLit(c.currentScope())))
+
+		c.ifCount++
+		c.pushScope(fmt.Sprintf("switch_%d", c.ifCount))
+		if s.Init != nil {
+			c.declareInit(s.Init)
+		}
+		var bound []string
+		if assign, ok := s.Assign.(*ast.AssignStmt); ok && len(assign.Lhs) == 1 {
+			if ident, ok := assign.Lhs[0].(*ast.Ident); ok {
+				bound = append(bound, ident.Name)
+			}
 		}
+		for _, stmt := range s.Body.List {
+			if cc, ok := stmt.(*ast.CaseClause); ok {
+				c.instrumentClause(cc.Pos(), caseLabel(c, cc.List), &cc.Body, bound)
+			}
+		}
+		c.popScope()
+		result = append(result, s)
 
-		// Instrument else body
-		if s.Else != nil {
-			switch e := s.Else.(type) {
-			case *ast.BlockStmt:
-				e.List = c.instrumentBlock(e.List, scope)
-			case *ast.IfStmt:
-				instrumented := c.instrumentStatement(e, scope)
-				if len(instrumented) > 0 {
-					s.Else = instrumented[len(instrumented)-1]
+	case *ast.SelectStmt:
+		for _, stmt := range s.Body.List {
+			if cc, ok := stmt.(*ast.CommClause); ok {
+				label := "default"
+				var bound []string
+				if cc.Comm != nil {
+					label = "case " + c.stmtText(cc.Comm)
+					if assign, ok := cc.Comm.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+						for _, lhs := range assign.Lhs {
+							if ident, ok := lhs.(*ast.Ident); ok {
+								bound = append(bound, ident.Name)
+							}
+						}
+					}
 				}
+				c.instrumentClause(cc.Pos(), label, &cc.Body, bound)
 			}
 		}
 		result = append(result, s)
 
+	case *ast.BlockStmt:
+		c.ifCount++
+		c.pushScope(fmt.Sprintf("block_%d", c.ifCount))
+		s.List = c.instrumentBlock(s.List)
+		c.popScope()
+		result = append(result, s)
+
+	case *ast.LabeledStmt:
+		inner := c.instrumentStatement(s.Stmt)
+		switch s.Stmt.(type) {
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			// break/continue need the label on the statement itself
+			s.Stmt = inner[len(inner)-1]
+			result = append(result, inner[:len(inner)-1]...)
+			result = append(result, s)
+		default:
+			// goto should land before the statement's traces
+			s.Stmt = inner[0]
+			result = append(result, s)
+			result = append(result, inner[1:]...)
+		}
+
 	case *ast.ExprStmt:
+		result = append(result, c.funcCallTraces(s)...)
 		result = append(result, stmt)
-		result = append(result, c.createTraceCall(s.Pos(), "call", scope))
+		result = append(result, c.createTraceCall(s, "call"))
+
+	case *ast.SendStmt, *ast.GoStmt, *ast.DeferStmt:
+		result = append(result, stmt)
+		result = append(result, c.createTraceCall(s, "call"))
 
 	case *ast.IncDecStmt:
 		result = append(result, stmt)
-		result = append(result, c.createTraceCall(s.Pos(), "assign", scope))
+		result = append(result, c.createTraceCall(s, "assign"))
 
 	case *ast.ReturnStmt:
-		result = append(result, c.createTraceCall(s.Pos(), "return", scope))
+		result = append(result, c.funcCallTraces(s)...)
+		if len(s.Results) == 0 {
+			result = append(result, c.createTraceCall(s, "func_return"))
+			result = append(result, stmt)
+		} else {
+			result = append(result, c.instrumentReturn(s))
+		}
+
+	case *ast.BranchStmt:
+		// fallthrough stays the last statement of its clause
+		result = append(result, c.createTraceCall(s, s.Tok.String()))
 		result = append(result, stmt)
 
 	default:
@@ -203,133 +371,287 @@ func (c *codeInstrumenter) instrumentStatement(stmt ast.Stmt, scope string) []as
 	return result
 }
 
-func (c *codeInstrumenter) addVariable(scope, name, typeName string) {
-	if c.variables[scope] == nil {
-		c.variables[scope] = make([]varInfo, 0)
-	}
-	// Check if variable already exists
-	for _, v := range c.variables[scope] {
-		if v.name == name {
-			return
+// instrumentReturn evaluates the results of s into temporaries of the
+// function's result types before tracing, so that the func_return step comes
+// after the calls and output in the results:
+//
+//	{ var __result0__ T; __result0__ = f(x); __trace__(...); return __result0__ }
+func (c *codeInstrumenter) instrumentReturn(s *ast.ReturnStmt) ast.Stmt {
+	trace := c.createTraceCall(s, "func_return")
+
+	var stmts []ast.Stmt
+	var temps []ast.Expr
+	for _, field := range c.results.List {
+		for i := 0; i < max(len(field.Names), 1); i++ {
+			temp := ast.NewIdent(fmt.Sprintf("__result%d__", len(temps)))
+			stmts = append(stmts, &ast.DeclStmt{Decl: &ast.GenDecl{
+				Tok:   token.VAR,
+				Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{temp}, Type: field.Type}},
+			}})
+			temps = append(temps, temp)
 		}
 	}
-	c.variables[scope] = append(c.variables[scope], varInfo{name: name, typeName: typeName})
+	stmts = append(stmts, &ast.AssignStmt{Lhs: temps, Tok: token.ASSIGN, Rhs: s.Results})
+	stmts = append(stmts, trace)
+	stmts = append(stmts, &ast.ReturnStmt{Return: s.Return, Results: temps})
+	return &ast.BlockStmt{List: stmts}
 }
 
-func (c *codeInstrumenter) getTypeName(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.ArrayType:
-		return "[]" + c.getTypeName(t.Elt)
-	case *ast.MapType:
-		return "map[" + c.getTypeName(t.Key) + "]" + c.getTypeName(t.Value)
-	default:
-		return "auto"
+// instrumentIf wraps the condition in a recording call and gives each branch
+// its own scope. Else-if chains are instrumented recursively.
+func (c *codeInstrumenter) instrumentIf(s *ast.IfStmt) ast.Stmt {
+	c.ifCount++
+	id := c.ifCount
+	line := c.fset.Position(s.Pos()).Line
+
+	c.pushScope(fmt.Sprintf("if_%d", id))
+	if s.Init != nil {
+		c.declareInit(s.Init)
 	}
+	s.Cond = c.condCall(line, "if_cond", "if condition", "", s.Cond)
+
+	bodyTrace := c.traceCall("__trace__", c.fset.Position(s.Body.Pos()).Line,
+		stringLit("if_body"), stringLit("if body"), stringLit(c.currentScope()))
+	s.Body.List = append([]ast.Stmt{bodyTrace}, c.instrumentBlock(s.Body.List)...)
+
+	switch el := s.Else.(type) {
+	case *ast.BlockStmt:
+		c.pushScope(fmt.Sprintf("else_%d", id))
+		elseTrace := c.traceCall("__trace__", c.fset.Position(el.Pos()).Line,
+			stringLit("else_body"), stringLit("else"), stringLit(c.currentScope()))
+		el.List = append([]ast.Stmt{elseTrace}, c.instrumentBlock(el.List)...)
+		c.popScope()
+	case *ast.IfStmt:
+		s.Else = c.instrumentIf(el)
+	}
+	c.popScope()
+
+	return s
 }
 
-func (c *codeInstrumenter) createTraceCall(pos token.Pos, stmtType, scope string) ast.Stmt {
-	line := c.fset.Position(pos).Line
+// instrumentClause records a case_match step when a clause body is entered
+func (c *codeInstrumenter) instrumentClause(pos token.Pos, label string, body *[]ast.Stmt, bound []string) {
+	c.pushScope("case")
+	for _, name := range bound {
+		c.addVariable(name)
+	}
+	matchTrace := c.traceCall("__trace__", c.fset.Position(pos).Line,
+		stringLit("case_match"), stringLit(label), stringLit(c.currentScope()))
+	*body = append([]ast.Stmt{matchTrace}, c.instrumentBlock(*body)...)
+	c.popScope()
+}
 
-	// Build variable capture string
-	varCaptures := c.buildVarCaptures(scope)
+// declareInit registers variables introduced by an if/switch init statement
+func (c *codeInstrumenter) declareInit(init ast.Stmt) {
+	if assign, ok := init.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+		for _, lhs := range assign.Lhs {
+			if ident, ok := lhs.(*ast.Ident); ok {
+				c.addVariable(ident.Name)
+			}
+		}
+	}
+}
 
-	// Create: __trace__(line, stmtType, scope, vars...)
-	return &ast.ExprStmt{
-		X: &ast.CallExpr{
-			Fun: &ast.Ident{Name: "__trace__"},
-			Args: []ast.Expr{
-				&ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(line)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, stmtType)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, scope)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, varCaptures)},
-			},
-		},
+// funcCallTraces emits a func_call step before statements that call a
+// user-defined function, mirroring the interpreter's func_call steps
+func (c *codeInstrumenter) funcCallTraces(stmt ast.Stmt) []ast.Stmt {
+	if stmt == nil {
+		return nil
 	}
+	var callee string
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if callee != "" {
+			return false
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		if call, ok := n.(*ast.CallExpr); ok {
+			if ident, ok := funcIdent(call.Fun); ok && c.functions[ident.Name] {
+				callee = ident.Name
+			}
+		}
+		return true
+	})
+	if callee == "" {
+		return nil
+	}
+	line := c.fset.Position(stmt.Pos()).Line
+	return []ast.Stmt{c.traceCall("__trace__", line,
+		stringLit("func_call"), stringLit(callee+"(...)"), stringLit(c.currentScope()))}
 }
 
-func (c *codeInstrumenter) createTraceCallWithLoop(pos token.Pos, stmtType, scope, loopID string) ast.Stmt {
-	line := c.fset.Position(pos).Line
-	varCaptures := c.buildVarCaptures(scope)
-
-	return &ast.ExprStmt{
-		X: &ast.CallExpr{
-			Fun: &ast.Ident{Name: "__traceLoop__"},
-			Args: []ast.Expr{
-				&ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(line)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, stmtType)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, scope)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, loopID)},
-				&ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf(`"%s"`, varCaptures)},
-			},
-		},
+func (c *codeInstrumenter) pushScope(name string) {
+	if len(c.blocks) > 0 {
+		name = c.currentScope() + "." + name
 	}
+	c.blocks = append(c.blocks, &blockScope{name: name})
+}
+
+func (c *codeInstrumenter) popScope() {
+	c.blocks = c.blocks[:len(c.blocks)-1]
 }
 
-func (c *codeInstrumenter) buildVarCaptures(scope string) string {
-	var vars []string
+func (c *codeInstrumenter) currentScope() string {
+	return c.blocks[len(c.blocks)-1].name
+}
+
+func (c *codeInstrumenter) addVariable(name string) {
+	if name == "_" {
+		return
+	}
+	block := c.blocks[len(c.blocks)-1]
+	// Check if variable already exists
+	for _, v := range block.vars {
+		if v.name == name {
+			return
+		}
+	}
+	block.vars = append(block.vars, varInfo{name: name, scope: block.name})
+}
 
-	// Collect variables from current scope and parent scopes
-	scopes := strings.Split(scope, ".")
-	for i := range scopes {
-		checkScope := strings.Join(scopes[:i+1], ".")
-		if scopeVars, ok := c.variables[checkScope]; ok {
-			for _, v := range scopeVars {
-				vars = append(vars, v.name)
+// visibleVars returns the variables in scope, innermost declaration winning
+func (c *codeInstrumenter) visibleVars() []varInfo {
+	seen := make(map[string]bool)
+	var vars []varInfo
+	for i := len(c.blocks) - 1; i >= 0; i-- {
+		for _, v := range c.blocks[i].vars {
+			if !seen[v.name] {
+				seen[v.name] = true
+				vars = append(vars, v)
 			}
 		}
 	}
+	// Report outer variables first
+	for i, j := 0, len(vars)-1; i < j; i, j = i+1, j-1 {
+		vars[i], vars[j] = vars[j], vars[i]
+	}
+	return vars
+}
 
-	return strings.Join(vars, ",")
+func (c *codeInstrumenter) createTraceCall(stmt ast.Stmt, stmtType string) ast.Stmt {
+	line := c.fset.Position(stmt.Pos()).Line
+	return c.traceCall("__trace__", line, stringLit(stmtType), stringLit(c.stmtText(stmt)), stringLit(c.currentScope()))
 }
 
-func getTraceRuntime() string {
-	return `
-// GoFlow Trace Runtime
-var __traceData__ []map[string]interface{}
-var __traceOutput__ string
-var __loopIterations__ = make(map[string]int)
-var __stepIndex__ int
-
-func __trace__(line int, stmtType, scope, varNames string) {
-	step := map[string]interface{}{
-		"stepIndex":     __stepIndex__,
-		"line":          line,
-		"statementType": stmtType,
-		"scopeStack":    strings.Split(scope, "."),
-	}
-	__traceData__ = append(__traceData__, step)
-	__stepIndex__++
+// traceCall builds: fn(line, args..., "name@scope,...", values...)
+func (c *codeInstrumenter) traceCall(fn string, line int, args ...ast.Expr) ast.Stmt {
+	return &ast.ExprStmt{X: c.runtimeCall(fn, line, args...)}
 }
 
-func __traceLoop__(line int, stmtType, scope, loopID, varNames string) {
-	__loopIterations__[loopID]++
-	step := map[string]interface{}{
-		"stepIndex":     __stepIndex__,
-		"line":          line,
-		"statementType": stmtType,
-		"scopeStack":    strings.Split(scope, "."),
-		"loopIteration": map[string]interface{}{
-			"loopId":    loopID,
-			"iteration": __loopIterations__[loopID],
-		},
-	}
-	__traceData__ = append(__traceData__, step)
-	__stepIndex__++
+func (c *codeInstrumenter) runtimeCall(fn string, line int, args ...ast.Expr) *ast.CallExpr {
+	vars := c.visibleVars()
+	names := make([]string, len(vars))
+	for i, v := range vars {
+		names[i] = v.name + "@" + v.scope
+	}
+
+	callArgs := []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(line)}}
+	callArgs = append(callArgs, args...)
+	callArgs = append(callArgs, stringLit(strings.Join(names, ",")))
+	for _, v := range vars {
+		callArgs = append(callArgs, ast.NewIdent(v.name))
+	}
+
+	return &ast.CallExpr{Fun: ast.NewIdent(fn), Args: callArgs}
+}
+
+// condCall wraps a loop or if condition: __cond__(line, type, stmt, scope, loopID, cond, vars, values...)
+func (c *codeInstrumenter) condCall(line int, stmtType, statement, loopID string, cond ast.Expr) ast.Expr {
+	return c.runtimeCall("__cond__", line,
+		stringLit(stmtType), stringLit(statement), stringLit(c.currentScope()), stringLit(loopID), cond)
+}
+
+func (c *codeInstrumenter) stmtText(stmt ast.Stmt) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, c.fset, stmt)
+	return buf.String()
+}
+
+func (c *codeInstrumenter) exprText(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, c.fset, expr)
+	return buf.String()
+}
+
+func caseLabel(c *codeInstrumenter, list []ast.Expr) string {
+	if list == nil {
+		return "default"
+	}
+	parts := make([]string, len(list))
+	for i, expr := range list {
+		parts[i] = c.exprText(expr)
+	}
+	return "case " + strings.Join(parts, ", ")
+}
+
+func typeSwitchExpr(assign ast.Stmt) ast.Expr {
+	switch a := assign.(type) {
+	case *ast.AssignStmt:
+		return a.Rhs[0]
+	case *ast.ExprStmt:
+		return a.X
+	}
+	return ast.NewIdent("?")
 }
 
-func __captureOutput__(s string) {
-	__traceOutput__ += s
-	fmt.Print(s)
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	default:
+		return "?"
+	}
 }
 
-func __getTrace__() []map[string]interface{} {
-	return __traceData__
+// receiverTypeParams returns the type parameters the receiver of a generic
+// type's method declares, as T in (s *Stack[T]), or nil when there are none
+// or one is left blank
+func receiverTypeParams(fn *ast.FuncDecl) []*ast.Ident {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return nil
+	}
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	var exprs []ast.Expr
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		exprs = []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		exprs = t.Indices
+	}
+	params := make([]*ast.Ident, 0, len(exprs))
+	for _, expr := range exprs {
+		ident, ok := expr.(*ast.Ident)
+		if !ok || ident.Name == "_" {
+			return nil
+		}
+		params = append(params, ident)
+	}
+	return params
 }
 
-func __getOutput__() string {
-	return __traceOutput__
+// funcIdent returns the name of the function fun denotes, leaving out the
+// type arguments of an explicit instantiation such as Max[int]
+func funcIdent(fun ast.Expr) (*ast.Ident, bool) {
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		fun = f.X
+	case *ast.IndexListExpr:
+		fun = f.X
+	}
+	ident, ok := fun.(*ast.Ident)
+	return ident, ok
 }
-`
+
+func stringLit(s string) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(s)}
 }
@@ -89,7 +89,7 @@ func processStatement(fset *token.FileSet, stmt ast.Stmt, parentID string, gener
 		nodeType := "statement"
 		// Detect user-defined function calls (not pkg.Method calls)
 		if call, ok := s.X.(*ast.CallExpr); ok {
-			if _, ok := call.Fun.(*ast.Ident); ok {
+			if _, ok := funcIdent(call.Fun); ok {
 				nodeType = "func_call"
 			}
 		}
@@ -121,15 +121,29 @@ func processStatement(fset *token.FileSet, stmt ast.Stmt, parentID string, gener
 		}
 	case *ast.SwitchStmt:
 		return processSwitchStmt(fset, s, parentID, generateID)
+	case *ast.LabeledStmt:
+		// The label belongs to the statement's node, so jumps to it can be
+		// matched to the node. The node keeps the statement's lines, which
+		// its steps are reported at.
+		node := processStatement(fset, s.Stmt, parentID, generateID)
+		if node != nil {
+			node.Label = s.Label.Name + ": " + node.Label
+			node.GoLabel = s.Label.Name
+		}
+		return node
 	case *ast.BranchStmt:
-		return &ASTNode{
+		node := &ASTNode{
 			ID:        generateID("branch"),
 			Type:      "statement",
-			Label:     s.Tok.String(),
+			Label:     getStatementText(fset, stmt),
 			StartLine: fset.Position(s.Pos()).Line,
 			EndLine:   fset.Position(s.End()).Line,
 			ParentID:  parentID,
 		}
+		if s.Label != nil {
+			node.Target = s.Label.Name
+		}
+		return node
 	default:
 		return nil
 	}
@@ -268,26 +282,13 @@ func processSwitchStmt(fset *token.FileSet, s *ast.SwitchStmt, parentID string,
 }
 
 func getFuncLabel(fset *token.FileSet, fn *ast.FuncDecl) string {
-	label := "func " + fn.Name.Name + "("
-
-	// Add parameters
-	if fn.Type.Params != nil {
-		var params []string
-		for _, field := range fn.Type.Params.List {
-			typeName := getExprText(field.Type)
-			var names []string
-			for _, name := range field.Names {
-				names = append(names, name.Name)
-			}
-			if len(names) > 0 {
-				params = append(params, strings.Join(names, ", ")+" "+typeName)
-			} else {
-				params = append(params, typeName)
-			}
-		}
-		label += strings.Join(params, ", ")
+	label := "func " + fn.Name.Name
+
+	// Add type parameters and parameters
+	if fn.Type.TypeParams != nil {
+		label += "[" + getFieldsText(fn.Type.TypeParams) + "]"
 	}
-	label += ")"
+	label += "(" + getFieldsText(fn.Type.Params) + ")"
 
 	// Add return type
 	if fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
@@ -305,6 +306,27 @@ func getFuncLabel(fset *token.FileSet, fn *ast.FuncDecl) string {
 	return label
 }
 
+// getFieldsText formats parameters as they are declared, as a, b int
+func getFieldsText(fields *ast.FieldList) string {
+	if fields == nil {
+		return ""
+	}
+	var params []string
+	for _, field := range fields.List {
+		typeName := getExprText(field.Type)
+		var names []string
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) > 0 {
+			params = append(params, strings.Join(names, ", ")+" "+typeName)
+		} else {
+			params = append(params, typeName)
+		}
+	}
+	return strings.Join(params, ", ")
+}
+
 func getExprText(expr ast.Expr) string {
 	switch t := expr.(type) {
 	case *ast.Ident:
@@ -313,6 +335,24 @@ func getExprText(expr ast.Expr) string {
 		return "[]" + getExprText(t.Elt)
 	case *ast.StarExpr:
 		return "*" + getExprText(t.X)
+	case *ast.SelectorExpr:
+		return getExprText(t.X) + "." + t.Sel.Name
+	case *ast.MapType:
+		return "map[" + getExprText(t.Key) + "]" + getExprText(t.Value)
+	case *ast.IndexExpr:
+		// A generic type instantiated, as Stack[T]
+		return getExprText(t.X) + "[" + getExprText(t.Index) + "]"
+	case *ast.IndexListExpr:
+		args := make([]string, len(t.Indices))
+		for i, index := range t.Indices {
+			args[i] = getExprText(index)
+		}
+		return getExprText(t.X) + "[" + strings.Join(args, ", ") + "]"
+	case *ast.BinaryExpr:
+		// A constraint's union of types, as ~int | ~float64
+		return getExprText(t.X) + " " + t.Op.String() + " " + getExprText(t.Y)
+	case *ast.UnaryExpr:
+		return t.Op.String() + getExprText(t.X)
 	default:
 		return "?"
 	}
@@ -354,7 +394,7 @@ func getStatementText(fset *token.FileSet, stmt ast.Stmt) string {
 					return pkg.Name + "." + sel.Sel.Name + "(...)"
 				}
 			}
-			if ident, ok := call.Fun.(*ast.Ident); ok {
+			if ident, ok := funcIdent(call.Fun); ok {
 				return ident.Name + "(...)"
 			}
 		}
@@ -370,6 +410,9 @@ func getStatementText(fset *token.FileSet, stmt ast.Stmt) string {
 		}
 		return "inc/dec"
 	case *ast.BranchStmt:
+		if s.Label != nil {
+			return s.Tok.String() + " " + s.Label.Name
+		}
 		return s.Tok.String()
 	case *ast.SwitchStmt:
 		return "switch"
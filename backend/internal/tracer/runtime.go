@@ -0,0 +1,330 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TraceRecord is one line of the JSON trace written by an instrumented program
+type TraceRecord struct {
+	Line          int              `json:"line"`
+	StatementType string           `json:"statementType"`
+	Statement     string           `json:"statement"`
+	Scope         string           `json:"scope"`
+	CallStack     []string         `json:"callStack"`
+	LoopID        string           `json:"loopId,omitempty"`
+	Iteration     int              `json:"iteration,omitempty"`
+	Condition     *bool            `json:"condition,omitempty"`
+	Variables     []TraceRecordVar `json:"variables"`
+	Output        string           `json:"output,omitempty"`
+	Goroutine     int              `json:"goroutine,omitempty"`
+}
+
+// TraceRecordVar is a variable as serialized by the trace runtime: its dynamic
+// type and its %#v representation
+type TraceRecordVar struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Scope string `json:"scope"`
+}
+
+// Environment variables read by the trace runtime
+const (
+	TraceFDEnv  = "GOFLOW_TRACE_FD"
+	OutputFDEnv = "GOFLOW_OUTPUT_FD"
+	MaxStepsEnv = "GOFLOW_MAX_STEPS"
+)
+
+// DecodeTrace reads the records written by an instrumented program and
+// converts them into the same steps the interpreter produces
+func DecodeTrace(r io.Reader) ([]Step, error) {
+	steps := make([]Step, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return steps, fmt.Errorf("trace decode error: %w", err)
+		}
+		steps = append(steps, rec.toStep(len(steps)))
+	}
+	if err := scanner.Err(); err != nil {
+		return steps, fmt.Errorf("trace decode error: %w", err)
+	}
+
+	return steps, nil
+}
+
+func (rec TraceRecord) toStep(index int) Step {
+	vars := make([]Variable, 0, len(rec.Variables))
+	for _, v := range rec.Variables {
+		vars = append(vars, Variable{
+			Name:  v.Name,
+			Type:  v.Type,
+			Value: parseNativeValue(v.Type, v.Value),
+			Scope: v.Scope,
+		})
+	}
+
+	funcName := "main"
+	if len(rec.CallStack) > 0 {
+		funcName = rec.CallStack[len(rec.CallStack)-1]
+	}
+
+	step := Step{
+		StepIndex:       index,
+		Line:            rec.Line,
+		Statement:       rec.Statement,
+		StatementType:   rec.StatementType,
+		Variables:       vars,
+		ScopeStack:      strings.Split(rec.Scope, "."),
+		Output:          rec.Output,
+		CallStack:       rec.CallStack,
+		FunctionName:    funcName,
+		ConditionResult: rec.Condition,
+		Goroutine:       rec.Goroutine,
+	}
+	if rec.LoopID != "" {
+		step.LoopIteration = &LoopIteration{LoopID: rec.LoopID, Iteration: rec.Iteration}
+	}
+	return step
+}
+
+// parseNativeValue turns the %#v form of basic values back into JSON values so
+// the frontend renders them like interpreter values. Composite values are kept
+// as their Go syntax.
+func parseNativeValue(typeName, repr string) interface{} {
+	switch typeName {
+	case "int", "int8", "int16", "int32", "int64":
+		if v, err := strconv.ParseInt(repr, 0, 64); err == nil {
+			return v
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		if v, err := strconv.ParseUint(repr, 0, 64); err == nil {
+			return v
+		}
+	case "float32", "float64":
+		if v, err := strconv.ParseFloat(repr, 64); err == nil {
+			return v
+		}
+	case "bool":
+		if v, err := strconv.ParseBool(repr); err == nil {
+			return v
+		}
+	case "string":
+		if v, err := strconv.Unquote(repr); err == nil {
+			return v
+		}
+	case "<nil>":
+		return nil
+	}
+	return repr
+}
+
+// TraceRuntime returns the source of the file that must be compiled alongside
+// InstrumentCode output. Imports are aliased so they can't collide with
+// identifiers declared by the user's program.
+func TraceRuntime() string {
+	return traceRuntime
+}
+
+const traceRuntime = `// Code generated by GoFlow. DO NOT EDIT.
+
+package main
+
+import (
+	__json "encoding/json"
+	__fmt "fmt"
+	__io "io"
+	__os "os"
+	__runtime "runtime"
+	__strconv "strconv"
+	__strings "strings"
+	__sync "sync"
+)
+
+type __traceVar__ struct {
+	Name  string ` + "`json:\"name\"`" + `
+	Type  string ` + "`json:\"type\"`" + `
+	Value string ` + "`json:\"value\"`" + `
+	Scope string ` + "`json:\"scope\"`" + `
+}
+
+type __traceRecord__ struct {
+	Line          int            ` + "`json:\"line\"`" + `
+	StatementType string         ` + "`json:\"statementType\"`" + `
+	Statement     string         ` + "`json:\"statement\"`" + `
+	Scope         string         ` + "`json:\"scope\"`" + `
+	CallStack     []string       ` + "`json:\"callStack\"`" + `
+	LoopID        string         ` + "`json:\"loopId,omitempty\"`" + `
+	Iteration     int            ` + "`json:\"iteration,omitempty\"`" + `
+	Condition     *bool          ` + "`json:\"condition,omitempty\"`" + `
+	Variables     []__traceVar__ ` + "`json:\"variables\"`" + `
+	Output        string         ` + "`json:\"output,omitempty\"`" + `
+	Goroutine     int            ` + "`json:\"goroutine\"`" + `
+}
+
+// __goroutine__ is the call stack and loop counters of one goroutine
+type __goroutine__ struct {
+	id    int
+	stack []string
+	loops map[string]int
+}
+
+const __maxValueLen__ = 4096
+
+var (
+	__traceMu__        __sync.Mutex
+	__traceEncoder__   *__json.Encoder
+	__traceOutput__    __io.Reader
+	__goroutines__     = make(map[int]*__goroutine__)
+	__traceSteps__     int
+	__traceMaxSteps__  = 10000
+)
+
+func init() {
+	// The host opens the trace file and a reader on stdout and passes them
+	// down, since the filesystem may be read-only
+	if fd, err := __strconv.Atoi(__os.Getenv("` + TraceFDEnv + `")); err == nil {
+		__traceEncoder__ = __json.NewEncoder(__os.NewFile(uintptr(fd), "trace"))
+	}
+	if fd, err := __strconv.Atoi(__os.Getenv("` + OutputFDEnv + `")); err == nil {
+		__traceOutput__ = __os.NewFile(uintptr(fd), "stdout")
+	}
+	if n, err := __strconv.Atoi(__os.Getenv("` + MaxStepsEnv + `")); err == nil && n > 0 {
+		__traceMaxSteps__ = n
+	}
+}
+
+// __current__ returns the state of the calling goroutine, keyed by the id
+// the Go runtime prints in its stack traces; callers hold __traceMu__
+func __current__() *__goroutine__ {
+	var buf [64]byte
+	// "goroutine 18 [running]:"
+	fields := __strings.Fields(string(buf[:__runtime.Stack(buf[:], false)]))
+	id := 0
+	if len(fields) > 1 {
+		id, _ = __strconv.Atoi(fields[1])
+	}
+	g := __goroutines__[id]
+	if g == nil {
+		g = &__goroutine__{id: id, loops: make(map[string]int)}
+		__goroutines__[id] = g
+	}
+	return g
+}
+
+// __emit__ writes one record for g; callers hold __traceMu__
+func __emit__(g *__goroutine__, rec __traceRecord__, vars string, values []interface{}) {
+	if __traceEncoder__ == nil || __traceSteps__ >= __traceMaxSteps__ {
+		return
+	}
+	__traceSteps__++
+
+	rec.Goroutine = g.id
+	rec.CallStack = append([]string{}, g.stack...)
+	rec.Variables = make([]__traceVar__, 0, len(values))
+	if vars != "" {
+		for i, entry := range __strings.Split(vars, ",") {
+			if i >= len(values) {
+				break
+			}
+			name, scope, _ := __strings.Cut(entry, "@")
+			value := __fmt.Sprintf("%#v", values[i])
+			if len(value) > __maxValueLen__ {
+				value = value[:__maxValueLen__] + "..."
+			}
+			rec.Variables = append(rec.Variables, __traceVar__{
+				Name:  name,
+				Type:  __fmt.Sprintf("%T", values[i]),
+				Value: value,
+				Scope: scope,
+			})
+		}
+	}
+
+	// Whatever the program wrote to stdout since the last step
+	if __traceOutput__ != nil {
+		if out, err := __io.ReadAll(__traceOutput__); err == nil {
+			rec.Output = string(out)
+		}
+	}
+
+	__traceEncoder__.Encode(rec)
+}
+
+func __trace__(line int, stmtType, stmt, scope, vars string, values ...interface{}) {
+	__traceMu__.Lock()
+	defer __traceMu__.Unlock()
+	__emit__(__current__(), __traceRecord__{Line: line, StatementType: stmtType, Statement: stmt, Scope: scope}, vars, values)
+}
+
+func __cond__(line int, stmtType, stmt, scope, loopID string, cond bool, vars string, values ...interface{}) bool {
+	__traceMu__.Lock()
+	defer __traceMu__.Unlock()
+	g := __current__()
+	rec := __traceRecord__{Line: line, StatementType: stmtType, Statement: stmt, Scope: scope, Condition: &cond}
+	if loopID != "" {
+		if cond {
+			g.loops[loopID]++
+		}
+		rec.LoopID = loopID
+		rec.Iteration = g.loops[loopID]
+	}
+	__emit__(g, rec, vars, values)
+	return cond
+}
+
+func __loopStart__(line int, stmt, scope, loopID, vars string, values ...interface{}) {
+	__traceMu__.Lock()
+	defer __traceMu__.Unlock()
+	g := __current__()
+	g.loops[loopID] = 0
+	__emit__(g, __traceRecord__{Line: line, StatementType: "for_init", Statement: stmt, Scope: scope}, vars, values)
+}
+
+func __loop__(line int, stmt, scope, loopID, vars string, values ...interface{}) {
+	__traceMu__.Lock()
+	defer __traceMu__.Unlock()
+	g := __current__()
+	g.loops[loopID]++
+	rec := __traceRecord__{Line: line, StatementType: "for_cond", Statement: stmt, Scope: scope,
+		LoopID: loopID, Iteration: g.loops[loopID]}
+	__emit__(g, rec, vars, values)
+}
+
+func __enter__(line int, name, scope, vars string, values ...interface{}) {
+	__traceMu__.Lock()
+	defer __traceMu__.Unlock()
+	g := __current__()
+	g.stack = append(g.stack, name)
+	__emit__(g, __traceRecord__{Line: line, StatementType: "func_enter", Statement: "enter " + name, Scope: scope}, vars, values)
+}
+
+// __instance__ names an instantiation of the generic function name, as
+// Max[int], from a (*T)(nil) for each of its type parameters
+func __instance__(name string, params ...interface{}) string {
+	args := make([]string, len(params))
+	for i, p := range params {
+		args[i] = __strings.ReplaceAll(__fmt.Sprintf("%T", p)[1:], "main.", "")
+	}
+	return name + "[" + __strings.Join(args, ", ") + "]"
+}
+
+func __leave__() {
+	__traceMu__.Lock()
+	defer __traceMu__.Unlock()
+	if g := __current__(); len(g.stack) > 0 {
+		g.stack = g.stack[:len(g.stack)-1]
+	}
+}
+`
@@ -2,10 +2,15 @@ package tracer
 
 // Variable represents a variable snapshot at a point in execution
 type Variable struct {
-	Name  string      `json:"name"`
-	Type  string      `json:"type"`
-	Value interface{} `json:"value"`
-	Scope string      `json:"scope"`
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Value   interface{} `json:"value"`
+	Display string      `json:"display,omitempty"` // e.g. 97 'a' for a rune
+	Scope   string      `json:"scope"`
+	Len     *int        `json:"len,omitempty"` // set for slices
+	Cap     *int        `json:"cap,omitempty"`
+	// Backing labels the array a slice shares with other variables
+	Backing string `json:"backing,omitempty"`
 }
 
 // LoopIteration tracks which iteration of a loop we're in
@@ -16,17 +21,56 @@ type LoopIteration struct {
 
 // Step represents a single execution step
 type Step struct {
-	StepIndex     int            `json:"stepIndex"`
-	Line          int            `json:"line"`
-	Column        int            `json:"column,omitempty"`
-	Statement     string         `json:"statement"`
-	StatementType string         `json:"statementType"`
-	Variables     []Variable     `json:"variables"`
-	ScopeStack    []string       `json:"scopeStack"`
-	Output        string         `json:"output,omitempty"`
-	LoopIteration *LoopIteration `json:"loopIteration,omitempty"`
-	CallStack     []string       `json:"callStack,omitempty"`
-	FunctionName  string         `json:"functionName,omitempty"`
+	StepIndex       int            `json:"stepIndex"`
+	Line            int            `json:"line"`
+	Column          int            `json:"column,omitempty"`
+	Statement       string         `json:"statement"`
+	StatementType   string         `json:"statementType"`
+	Variables       []Variable     `json:"variables"`
+	ScopeStack      []string       `json:"scopeStack"`
+	Output          string         `json:"output,omitempty"`
+	LoopIteration   *LoopIteration `json:"loopIteration,omitempty"`
+	CallStack       []string       `json:"callStack,omitempty"`
+	FunctionName    string         `json:"functionName,omitempty"`
+	ConditionResult *bool          `json:"conditionResult,omitempty"`
+	Goroutine       int            `json:"goroutine,omitempty"` // native mode only
+}
+
+// StatementTypes lists every Step.StatementType the executors emit
+var StatementTypes = []string{
+	"assign",
+	"declare",
+	"for_init",
+	"for_cond",
+	"for_post",
+	"if_cond",
+	"if_body",
+	"else_body",
+	"call",
+	"return",
+	"break",
+	"continue",
+	"goto",
+	"fallthrough",
+	"func_call",
+	"func_enter",
+	"func_return",
+	"switch_tag",
+	"case_match",
+	"panic",
+}
+
+// NodeTypes lists every ASTNode.Type the parser produces
+var NodeTypes = []string{
+	"function",
+	"for",
+	"if",
+	"else",
+	"statement",
+	"block",
+	"func_call",
+	"switch",
+	"case",
 }
 
 // ASTNode represents a node in the visualization tree
@@ -38,6 +82,10 @@ type ASTNode struct {
 	EndLine   int        `json:"endLine"`
 	Children  []*ASTNode `json:"children,omitempty"`
 	ParentID  string     `json:"parentId,omitempty"`
+	// GoLabel is the label of a labeled statement, as outer in outer: for
+	GoLabel string `json:"goLabel,omitempty"`
+	// Target is the label a break, continue or goto jumps to
+	Target string `json:"target,omitempty"`
 }
 
 // ASTResult contains the parsed AST for visualization
@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+func main() {
+	for i := 1; i <= 10; i++ {
+		if i == 8 {
+			fmt.Println("Stopping at", i)
+			break
+		}
+		if i%2 == 0 {
+			continue
+		}
+		fmt.Println(i)
+	}
+}
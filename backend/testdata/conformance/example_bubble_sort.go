@@ -0,0 +1,21 @@
+package main
+
+import "fmt"
+
+func main() {
+	arr := []int{5, 2, 8, 1, 9}
+	n := 5
+
+	for i := 0; i < n-1; i++ {
+		for j := 0; j < n-i-1; j++ {
+			if arr[j] > arr[j+1] {
+				// Swap
+				temp := arr[j]
+				arr[j] = arr[j+1]
+				arr[j+1] = temp
+			}
+		}
+	}
+
+	fmt.Println("Sorted:", arr)
+}
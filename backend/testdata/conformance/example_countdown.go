@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+func main() {
+	for i := 10; i >= 0; i-- {
+		if i == 0 {
+			fmt.Println("Liftoff!")
+		} else {
+			fmt.Println(i)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+func main() {
+	n := 5
+	result := 1
+
+	for i := 1; i <= n; i++ {
+		result = result * i
+		fmt.Println(i, "! =", result)
+	}
+}
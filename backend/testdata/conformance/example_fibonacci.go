@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+func main() {
+	n := 10
+	a := 0
+	b := 1
+
+	fmt.Println(a)
+	fmt.Println(b)
+
+	for i := 2; i < n; i++ {
+		c := a + b
+		fmt.Println(c)
+		a = b
+		b = c
+	}
+}
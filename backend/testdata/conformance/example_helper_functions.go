@@ -0,0 +1,24 @@
+package main
+
+import "fmt"
+
+func add(a int, b int) int {
+	sum := a + b
+	return sum
+}
+
+func multiply(a int, b int) int {
+	product := a * b
+	return product
+}
+
+func main() {
+	x := 3
+	y := 4
+	sum := add(x, y)
+	fmt.Println("Sum:", sum)
+	product := multiply(x, y)
+	fmt.Println("Product:", product)
+	combined := add(sum, product)
+	fmt.Println("Combined:", combined)
+}
@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+func main() {
+	size := 5
+
+	for i := 1; i <= size; i++ {
+		for j := 1; j <= size; j++ {
+			product := i * j
+			fmt.Print(product, " ")
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+func main() {
+	// Simple nested loop example
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 2; j++ {
+			fmt.Println(i, j)
+		}
+	}
+}
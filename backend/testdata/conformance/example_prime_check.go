@@ -0,0 +1,24 @@
+package main
+
+import "fmt"
+
+func main() {
+	num := 17
+	isPrime := true
+
+	if num < 2 {
+		isPrime = false
+	}
+
+	for i := 2; i*i <= num; i++ {
+		if num%i == 0 {
+			isPrime = false
+		}
+	}
+
+	if isPrime {
+		fmt.Println(num, "is prime")
+	} else {
+		fmt.Println(num, "is not prime")
+	}
+}
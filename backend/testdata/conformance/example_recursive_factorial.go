@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+func factorial(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	result := n * factorial(n-1)
+	return result
+}
+
+func main() {
+	num := 5
+	result := factorial(num)
+	fmt.Println("Factorial of", num, "is", result)
+}
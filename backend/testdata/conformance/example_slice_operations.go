@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+func main() {
+	nums := []int{10, 20, 30}
+	nums = append(nums, 40)
+	nums = append(nums, 50)
+
+	sum := 0
+	for i, v := range nums {
+		fmt.Println("Index:", i, "Value:", v)
+		sum = sum + v
+	}
+
+	fmt.Println("Total:", sum)
+}
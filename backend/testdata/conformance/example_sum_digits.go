@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+func main() {
+	num := 12345
+	sum := 0
+	original := num
+
+	for num > 0 {
+		digit := num % 10
+		sum = sum + digit
+		num = num / 10
+		fmt.Println("Digit:", digit, "Sum:", sum)
+	}
+
+	fmt.Println("Sum of digits of", original, "is", sum)
+}
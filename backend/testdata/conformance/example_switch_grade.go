@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+func main() {
+	score := 85
+	grade := ""
+
+	switch {
+	case score >= 90:
+		grade = "A"
+	case score >= 80:
+		grade = "B"
+	case score >= 70:
+		grade = "C"
+	case score >= 60:
+		grade = "D"
+	default:
+		grade = "F"
+	}
+
+	fmt.Println("Score:", score, "Grade:", grade)
+}
@@ -0,0 +1,21 @@
+// conformance: unordered
+package main
+
+import "fmt"
+
+func main() {
+	data := []int{3, 1, 4, 1, 5, 9, 2, 6, 5, 3}
+	counts := make(map[int]int)
+
+	for _, v := range data {
+		counts[v]++
+	}
+
+	fmt.Println("Counts:", counts)
+
+	for key, val := range counts {
+		if val > 1 {
+			fmt.Println(key, "appears", val, "times")
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+func main() {
+	a := 17
+	b := 5
+	fmt.Println(a+b, a-b, a*b, a/b, a%b)
+	fmt.Println(a > b, a < b, a == b, a != b, a >= 17, b <= 4)
+	x := 2.5
+	y := 0.5
+	fmt.Println(x+y, x*y, x/y)
+	ok := a > 10 && b < 10
+	fmt.Println(ok, ok || false, !ok)
+	s := "go" + "flow"
+	fmt.Println(s, len(s))
+}
@@ -0,0 +1,75 @@
+package main
+
+import "fmt"
+
+func winner(board [3][3]string) string {
+	for i := 0; i < 3; i++ {
+		if board[i][0] != "" && board[i][0] == board[i][1] && board[i][1] == board[i][2] {
+			return board[i][0]
+		}
+	}
+	return "none"
+}
+
+func reset(b [3][3]string) {
+	b[0][0] = ""
+}
+
+func main() {
+	var board [3][3]string
+	board[1][0] = "X"
+	board[1][1] = "X"
+	board[1][2] = "X"
+	board[0][2] = "O"
+	reset(board)
+	fmt.Println(board, winner(board), len(board), len(board[0]))
+
+	a := [5]int{1, 2, 3}
+	b := a
+	b[0] = 100
+	a[4]++
+	fmt.Println(a, b, a == b, a != [5]int{})
+
+	primes := [...]int{2, 3, 5, 7}
+	sparse := [6]int{1: 10, 4: 40, 50}
+	labels := []string{3: "d", 0: "a"}
+	fmt.Println(len(primes), sparse, len(labels), labels[3])
+
+	grid := [][]int{{1, 2, 3}, {4, 5, 6}}
+	grid[1][2] = 60
+	grid[0][0] += 9
+	row := grid[0]
+	row[1] = 20
+	grid = append(grid, []int{7, 8, 9})
+	fmt.Println(grid, row)
+
+	matrix := [2][2]float64{{1, 2}, {3, 4}}
+	m := matrix
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] *= 2
+		}
+	}
+	fmt.Println(matrix, m)
+
+	var counts [26]int
+	for _, r := range "hello" {
+		counts[r-'a']++
+	}
+	fmt.Println(counts['l'-'a'], counts['h'-'a'])
+
+	rows := [][3]int{{1, 2, 3}}
+	rows[0][1] = 7
+	cube := [][][]int{{{1}, {2, 3}}, {{4}}}
+	cube[0][1][1] = 30
+	byName := map[string][2]int{"a": {1, 2}}
+	byName["b"] = [2]int{3, 4}
+	nested := map[string][]string{"x": {"p", "q"}}
+	nested["x"][1] = "Q"
+	fmt.Println(rows, cube, byName, nested, len(cube[0][1]))
+
+	for i, v := range [3]string{"a", "b", "c"} {
+		fmt.Print(i, v, " ")
+	}
+	fmt.Println()
+}
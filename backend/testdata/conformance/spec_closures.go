@@ -0,0 +1,102 @@
+package main
+
+import "fmt"
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	out := make([]U, 0, len(s))
+	for _, v := range s {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func Filter[T any](s []T, keep func(T) bool) []T {
+	var out []T
+	for _, v := range s {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func double(x int) int {
+	return x * 2
+}
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func divmod(a, b int) (q, r int) {
+	q = a / b
+	r = a % b
+	return
+}
+
+func main() {
+	fmt.Println(Map([]int{1, 2}, func(x int) int { return x * 2 }))
+	fmt.Println(Map([]int{1, 2, 3}, double), Map([]string{"go", "flow"}, func(s string) int { return len(s) }))
+	fmt.Println(Filter([]int{1, 2, 3, 4, 5, 6}, func(x int) bool { return x%2 == 0 }))
+
+	next := counter()
+	next()
+	next()
+	other := counter()
+	fmt.Println(next(), other())
+
+	// Each iteration has its own i
+	var squares []func() int
+	for i := 0; i < 3; i++ {
+		squares = append(squares, func() int { return i * i })
+	}
+	for _, f := range squares {
+		fmt.Print(f(), " ")
+	}
+	fmt.Println()
+
+	// A closure keeps reading the variable it was written with, even once a
+	// later declaration in the block shadows it
+	if v := 1; v > 0 {
+		f := func() int { return v }
+		v := 5
+		fmt.Println(f(), v)
+	}
+	w := 2
+	g := func() int { return w * 10 }
+	{
+		w := 3
+		fmt.Println(g(), w)
+	}
+
+	total := 0
+	add := func(n int) { total += n }
+	add(3)
+	add(4)
+	fmt.Println(total)
+
+	var fib func(int) int
+	fib = func(n int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n-1) + fib(n-2)
+	}
+	fmt.Println(fib(10), func(a, b int) int { return a * b }(6, 7))
+
+	var nothing func()
+	fmt.Println(nothing == nil)
+
+	q, r := divmod(17, 5)
+	fmt.Println(q, r)
+	fmt.Println(divmod(9, 4))
+
+	ages := map[string]int{"ann": 30}
+	age, ok := ages["ann"]
+	_, missing := ages["bob"]
+	fmt.Println(age, ok, missing)
+}
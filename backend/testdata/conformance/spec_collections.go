@@ -0,0 +1,21 @@
+package main
+
+import "fmt"
+
+func main() {
+	nums := []int{5, 2, 8}
+	nums = append(nums, 1)
+	nums[0] = 9
+	fmt.Println(nums, len(nums), nums[2])
+
+	names := []string{"ann", "bob"}
+	names = append(names, "cy")
+	for i, name := range names {
+		fmt.Println(i, name)
+	}
+
+	ages := map[string]int{"ann": 31}
+	ages["bob"] = 27
+	delete(ages, "ann")
+	fmt.Println(ages["bob"], len(ages))
+}
@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+func classify(n int) string {
+	if n < 0 {
+		return "negative"
+	} else if n == 0 {
+		return "zero"
+	} else if n < 10 {
+		return "small"
+	}
+	return "large"
+}
+
+func main() {
+	for _, n := range []int{-3, 0, 7, 42} {
+		fmt.Println(n, classify(n))
+	}
+
+	total := 0
+	for i := 0; i < 20; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		if i > 12 {
+			break
+		}
+		total += i
+	}
+	fmt.Println("total", total)
+
+	n := 10
+	for n > 1 {
+		n = n / 2
+	}
+	fmt.Println("n", n)
+}
@@ -0,0 +1,11 @@
+package main
+
+import "fmt"
+
+func main() {
+	total := 10
+	count := 0
+	fmt.Println("before")
+	fmt.Println(total / count)
+	fmt.Println("after")
+}
@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+func gcd(a, b int) int {
+	if b == 0 {
+		return a
+	}
+	return gcd(b, a%b)
+}
+
+func greet(name string, times int) {
+	for i := 0; i < times; i++ {
+		fmt.Println("hello", name)
+	}
+}
+
+func main() {
+	fmt.Println(gcd(48, 18))
+	greet("gopher", 2)
+	fmt.Printf("%d-%s-%v\n", 7, "x", true)
+	fmt.Print("no newline", "\n")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+)
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+type Stack[T any] []T
+
+type Celsius float64
+
+func Max[T cmp.Ordered](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func Sum[T Number](values []T) T {
+	var total T
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func Average[T Number](values []T) float64 {
+	return float64(Sum(values)) / float64(len(values))
+}
+
+func Index[T comparable](s []T, x T) int {
+	for i, v := range s {
+		if v == x {
+			return i
+		}
+	}
+	return -1
+}
+
+func Reverse[T any](s []T) []T {
+	out := make([]T, 0, len(s))
+	for i := len(s) - 1; i >= 0; i-- {
+		out = append(out, s[i])
+	}
+	return out
+}
+
+func Zip[K comparable, V any](keys []K, values []V) map[K]V {
+	m := make(map[K]V)
+	for i, k := range keys {
+		m[k] = values[i]
+	}
+	return m
+}
+
+func Halve[T Number](x T) T {
+	return x / 2
+}
+
+func Push[T any](s Stack[T], v T) Stack[T] {
+	return append(s, v)
+}
+
+func SortedMax[T cmp.Ordered](s []T) T {
+	best := s[0]
+	for _, v := range s[1:] {
+		if cmp.Less(best, v) {
+			best = v
+		}
+	}
+	return best
+}
+
+func main() {
+	fmt.Println(Max(3, 7), Max("apple", "pear"), Max[float64](2, 2.5))
+	fmt.Println(Sum([]int{1, 2, 3}), Sum([]float64{0.5, 0.25}), Average([]int{1, 2}))
+	fmt.Println(Index([]string{"a", "b"}, "b"), Index([]int{1}, 5))
+	fmt.Println(Reverse([]rune("abc")), string(Reverse([]byte("go"))))
+	fmt.Println(Zip[string, int]([]string{"x", "y"}, []int{1, 2}))
+	fmt.Println(Halve(7), Halve(7.0), Halve(Celsius(41)))
+
+	var s Stack[string]
+	s = Push(s, "a")
+	s = Push(s, "b")
+	fmt.Println(s, len(s))
+
+	fmt.Println(SortedMax([]int{4, 9, 2}), cmp.Compare(1, 2), cmp.Compare("b", "a"), cmp.Less(2.0, 2.0))
+}
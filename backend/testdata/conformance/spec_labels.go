@@ -0,0 +1,84 @@
+package main
+
+import "fmt"
+
+func find(grid [][]int, target int) (int, int) {
+	row, col := -1, -1
+outer:
+	for i, line := range grid {
+		for j, v := range line {
+			if v == target {
+				row, col = i, j
+				break outer
+			}
+		}
+	}
+	fmt.Println("found", target, "at", row, col)
+	return row, col
+}
+
+func main() {
+	grid := [][]int{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}
+	find(grid, 5)
+
+rows:
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if j > i {
+				continue rows
+			}
+			fmt.Print(i*3+j, " ")
+		}
+	}
+	fmt.Println()
+
+	for n := 0; n < 5; n++ {
+		switch {
+		case n == 1:
+			fmt.Println("one, leaving the switch")
+			break
+		case n == 3:
+			fmt.Println("three")
+			fallthrough
+		case n == 100:
+			fmt.Println("fell through from", n)
+		default:
+			fmt.Println("default", n)
+		}
+	}
+
+	switch x := 2; x {
+	default:
+		fmt.Println("default written first")
+	case 2:
+		fmt.Println("two")
+		fallthrough
+	case 3:
+		fmt.Println("and three")
+	}
+
+loop:
+	for {
+		switch {
+		default:
+			break loop
+		}
+	}
+
+	i := 0
+again:
+	if i < 4 {
+		fmt.Println("goto", i)
+		i++
+		goto again
+	}
+
+	for k := 0; k < 3; k++ {
+		if k == 1 {
+			goto done
+		}
+		fmt.Println("k", k)
+	}
+done:
+	fmt.Println("done", i)
+}
@@ -0,0 +1,53 @@
+package main
+
+import "fmt"
+
+func main() {
+	// Compound assignment
+	sum := 0
+	for _, x := range []int{3, 4, 5} {
+		sum += x
+	}
+	prod := 1
+	prod *= 6
+	prod -= 2
+	prod /= 3
+	prod %= 5
+	fmt.Println(sum, prod)
+
+	// Strings
+	word := ""
+	for _, s := range []string{"go", "flow"} {
+		word += s
+	}
+	fmt.Println(word, word < "help", word >= "goflow", word+"!")
+
+	// Bitwise
+	flags := 0b1010
+	flags |= 1
+	flags &= 0b1110
+	flags ^= 0xF0
+	flags &^= 0x80
+	fmt.Println(flags, flags<<3, flags>>2, 6&3, 6|3, 6^3, 6&^3)
+
+	// Untyped constants take the type of the other operand
+	f := 2.0
+	f += 1
+	fmt.Println(f/4, 7/2, 7/2.0, 1<<10, -7/2, -7%3, -7>>1)
+
+	// Fixed-size arithmetic wraps around
+	var b uint8 = 250
+	b += 10
+	var i8 int8 = 127
+	i8++
+	fmt.Println(b, i8)
+
+	// Tuple assignment evaluates everything first
+	x, y := 1, 2
+	x, y = y, x
+	fmt.Println(x, y)
+
+	// && and || short-circuit
+	nums := []int{}
+	fmt.Println(len(nums) > 0 && nums[0] > 0, len(nums) == 0 || nums[0] > 0)
+}
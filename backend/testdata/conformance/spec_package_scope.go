@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+const (
+	Sunday int8 = iota
+	Monday
+	Tuesday
+)
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+	GB
+)
+
+const greeting = "hello"
+const ratio float32 = 0.5
+
+var total = sum(limit)
+var limit = double(base) + 1
+var base = 3
+var counter int
+var names []string
+var registry = map[string]int{}
+
+func double(n int) int {
+	return n * 2
+}
+
+func sum(n int) int {
+	s := 0
+	for i := 1; i <= n; i++ {
+		s += i
+	}
+	return s
+}
+
+func init() {
+	counter = 10
+	names = append(names, "first")
+}
+
+func init() {
+	counter++
+	registry["init"] = counter
+}
+
+func bump() {
+	counter += 5
+}
+
+func main() {
+	fmt.Println(Sunday, Monday, Tuesday, KB, MB, GB)
+	fmt.Println(greeting, ratio, base, limit, total)
+	fmt.Println(counter, names, registry["init"])
+	bump()
+	bump()
+	fmt.Println(counter)
+
+	const local = iota + 7
+	const (
+		a = iota * 2
+		b
+		c
+	)
+	counter := "shadowed"
+	fmt.Println(local, a, b, c, counter)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+func main() {
+	x := 1
+	if x := 2; x > 1 {
+		x := x * 10
+		fmt.Println("if", x)
+	} else {
+		fmt.Println("else", x)
+	}
+	fmt.Println("after if", x)
+
+	for i := 0; i < 3; i++ {
+		x := i
+		i := i + 100
+		fmt.Println("loop", x, i)
+	}
+	i := "outer i"
+	fmt.Println(i)
+
+	for _, x := range []int{7, 8} {
+		x++
+		fmt.Println("range", x)
+	}
+	fmt.Println("after range", x)
+
+	{
+		x := "block"
+		fmt.Println(x)
+	}
+
+	switch y := x + 1; y {
+	case 2:
+		x := "case"
+		fmt.Println(x, y)
+	default:
+		fmt.Println("default")
+	}
+
+	total := 0
+	for n := 1; n <= 3; n++ {
+		total += n
+		x = total
+	}
+	fmt.Println(total, x)
+}
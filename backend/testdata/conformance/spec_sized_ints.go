@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+func checksum(data []byte) uint8 {
+	var sum uint8
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
+
+func main() {
+	var a int8 = 100
+	a += 100
+	var u uint16 = 65535
+	u++
+	var big int64 = 1 << 40
+	x := 7
+	half := float64(x) / 2
+	n := int64(x) * big
+	var h uint32 = 2166136261
+	h ^= uint32('a')
+	h *= 16777619
+	var r rune = 'Z'
+	var by byte = 'a'
+	f32 := float32(1) / 3
+	c := complex(1.5, 2)
+	trunc := int(-3.9 + half)
+	wrapped := uint8(x * 50)
+	fmt.Println(a, u, big, half, n, h, r, by, f32, c, real(c), imag(c), trunc, wrapped)
+	fmt.Println(checksum([]byte{200, 100, 7}), string(rune(65)), uint(1)<<63, int8(-128)/int8(x-8))
+	var up uintptr = 8
+	var zero float64
+	var cs []int16
+	cs = append(cs, 1, 2)
+	fmt.Printf("%T %T %T %v %v %T\n", up, zero, r, cs, zero/zero, c)
+}
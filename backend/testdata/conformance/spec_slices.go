@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+func fill(s []int, v int) {
+	for i := range s {
+		s[i] = v
+	}
+}
+
+func main() {
+	arr := [6]int{0, 1, 2, 3, 4, 5}
+	s := arr[1:4]
+	t := s[1:]
+	t[0] = 20
+	fmt.Println(arr, s, t, len(s), cap(s), len(t), cap(t))
+
+	// Appending within the capacity writes into the shared array
+	s = append(s, 40)
+	fmt.Println(arr, s, len(s), cap(s))
+
+	// Outgrowing it copies the elements to a new array
+	u := arr[4:]
+	u = append(u, 6, 7)
+	u[0] = 400
+	fmt.Println(arr[4], u, len(u), cap(u) >= 4)
+
+	var grown []int
+	caps := []int{}
+	for i := 0; i < 10; i++ {
+		grown = append(grown, i)
+		caps = append(caps, cap(grown))
+	}
+	fmt.Println(grown, caps)
+
+	limited := arr[1:3:4]
+	fmt.Println(limited, len(limited), cap(limited))
+	limited = append(limited, 30, 31)
+	limited[0] = -1
+	fmt.Println(arr, limited)
+
+	m := make([]int, 3, 10)
+	n := m[:5]
+	fill(n, 9)
+	fmt.Println(m, n, len(m), cap(m), cap(n[2:]))
+
+	dst := make([]int, 2)
+	copied := copy(dst, []int{7, 8, 9})
+	buf := make([]byte, 3)
+	fmt.Println(dst, copied, copy(buf, "hey!"), string(buf))
+	overlap := []int{1, 2, 3, 4, 5}
+	copy(overlap[1:], overlap)
+	fmt.Println(overlap)
+
+	fmt.Println(min(3, 1, 2), max(2.5, 1), min("b", "a"), max(overlap[0], overlap[4]))
+
+	clear(overlap[:2])
+	scores := map[string]int{"a": 1, "b": 2}
+	clear(scores)
+	fmt.Println(overlap, len(scores))
+
+	var nilSlice []int
+	fmt.Println(len(nilSlice), cap(nilSlice), nilSlice == nil, len(nilSlice[:0]))
+	b := append([]byte("go"), "pher"...)
+	fmt.Println(string(b))
+
+	hi := 5
+	fmt.Println(s[:hi])
+	fmt.Println(s[:hi+2])
+}
@@ -0,0 +1,39 @@
+package main
+
+import "fmt"
+
+func main() {
+	s := "héllo, 世界"
+	fmt.Println(len(s), s[0], s[1], s[2])
+	for i, r := range s {
+		fmt.Println(i, r, string(r))
+	}
+	for i := range "ab" {
+		fmt.Println(i)
+	}
+
+	b := []byte("abc")
+	b[0] = 'A'
+	runes := []rune(s)
+	fmt.Println(b, string(b), len(runes), string(runes[1]), string(runes[7:]))
+
+	fmt.Println(s[:5], s[7:], s[1:3] == "é", s[:])
+	var c byte = 'z'
+	r := 'λ'
+	fmt.Println(c, r, string(c), string(r), c-'a', r+1)
+
+	reversed := ""
+	for _, r := range "stressed" {
+		reversed = string(r) + reversed
+	}
+	fmt.Println(reversed)
+
+	word := "level"
+	palindrome := true
+	for i, j := 0, len(word)-1; i < j; i, j = i+1, j-1 {
+		if word[i] != word[j] {
+			palindrome = false
+		}
+	}
+	fmt.Println(palindrome, "\xff"[0], []rune("\xffa"))
+}
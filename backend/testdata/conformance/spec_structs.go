@@ -0,0 +1,104 @@
+package main
+
+import "fmt"
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+func (s Stack[T]) Len() int {
+	return len(s.items)
+}
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+type Point struct {
+	X, Y int
+}
+
+func (p Point) Add(q Point) Point {
+	return Point{p.X + q.X, p.Y + q.Y}
+}
+
+func (p *Point) Scale(k int) {
+	p.X *= k
+	p.Y *= k
+}
+
+type Grid struct {
+	name  string
+	cells [2][2]int
+	tags  map[string]int
+}
+
+func reset(g Grid) {
+	g.name = "changed"
+}
+
+func rename(g *Grid, name string) {
+	g.name = name
+}
+
+func main() {
+	var s Stack[int]
+	s.Push(1)
+	s.Push(2)
+	s.Push(3)
+	v, ok := s.Pop()
+	fmt.Println(v, ok, s.Len(), s)
+
+	words := &Stack[string]{}
+	words.Push("a")
+	words.Push("b")
+	for words.Len() > 0 {
+		w, _ := words.Pop()
+		fmt.Print(w, " ")
+	}
+	_, ok = words.Pop()
+	fmt.Println(ok, *words)
+
+	pairs := []Pair[string, int]{{"a", 1}, {Key: "b", Val: 2}}
+	pairs[1].Val *= 10
+	fmt.Printf("%v %+v\n", pairs, pairs[1])
+
+	p := Point{1, 2}
+	q := p
+	q.X = 10
+	p.Scale(3)
+	fmt.Println(p, q, p.Add(q), p == Point{3, 6})
+
+	pts := [2]Point{}
+	first := &pts[0]
+	first.Y = 5
+	pts[1].X = 7
+	fmt.Println(pts)
+
+	g := Grid{name: "g", tags: map[string]int{}}
+	g.cells[1][0] = 4
+	g.tags["x"]++
+	reset(g)
+	fmt.Printf("%+v\n", g)
+	rename(&g, "h")
+	fmt.Println(g.name)
+
+	push := s.Push
+	push(9)
+	fmt.Println(s.items)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+func main() {
+	for i := 0; i < 4; i++ {
+		switch i {
+		case 0:
+			fmt.Println("zero")
+		case 1, 2:
+			fmt.Println("one or two")
+		default:
+			fmt.Println("other")
+		}
+	}
+
+	score := 73
+	switch {
+	case score >= 90:
+		fmt.Println("A")
+	case score >= 70:
+		fmt.Println("C")
+	default:
+		fmt.Println("F")
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+func main() {
+	x := 5
+	found := false
+	var mask uint8 = 0x0F
+	var small int8 = -128
+	fmt.Println(-x, +x, !found, ^mask, ^x, -small, -2.5, !(x > 3))
+	visited := map[int]bool{}
+	if !visited[3] {
+		fmt.Println("not visited")
+	}
+}
@@ -25,7 +25,8 @@ export function VariableTracker({ variables, previousVariables = [] }: VariableT
   const groupedVars = useMemo(() => {
     const groups: Record<string, Variable[]> = {};
     for (const v of variables) {
-      // Extract function name from scope (e.g., "main.for_1" -> "main")
+      // Extract function name from scope (e.g., "main.for_1" -> "main");
+      // globals are in the "package" scope
       const funcName = v.scope.split('.')[0] || 'main';
       if (!groups[funcName]) groups[funcName] = [];
       groups[funcName].push(v);
@@ -85,7 +86,7 @@ export function VariableTracker({ variables, previousVariables = [] }: VariableT
                       <svg className="w-3 h-3" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                         <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M10 20l4-16m4 4l4 4-4 4M6 16l-4-4 4-4" />
                       </svg>
-                      {scopeName}()
+                      {scopeName === 'package' ? 'package' : `${scopeName}()`}
                     </span>
                   </td>
                 </tr>
@@ -104,9 +105,29 @@ export function VariableTracker({ variables, previousVariables = [] }: VariableT
                       <span className={hasChanged ? 'text-warning' : ''}>
                         {variable.name}
                       </span>
+                      {variable.scope.includes('.') && (
+                        // Variables of inner blocks, which may shadow outer ones
+                        <span className="ml-1 text-xs font-normal text-base-content/50">
+                          {variable.scope.slice(variable.scope.indexOf('.') + 1)}
+                        </span>
+                      )}
                     </td>
                     <td className="text-base-content/60 font-mono text-xs">
                       {variable.type}
+                      {variable.cap !== undefined && (
+                        <span className="block">
+                          len {variable.len} cap {variable.cap}
+                        </span>
+                      )}
+                      {variable.backing && (
+                        // Slices and arrays with the same label share elements
+                        <span
+                          className="badge badge-xs badge-info ml-1"
+                          title="Shares its elements with the other variables labelled the same"
+                        >
+                          {variable.backing}
+                        </span>
+                      )}
                     </td>
                     <td className="font-mono">
                       <span
@@ -115,7 +136,12 @@ export function VariableTracker({ variables, previousVariables = [] }: VariableT
                           ${hasChanged ? 'bg-warning text-warning-content animate-pulse' : 'bg-base-200'}
                         `}
                       >
-                        {formatValue(variable.value)}
+                        {variable.display ??
+                          (isGrid(variable.value) ? (
+                            <GridValue rows={variable.value} />
+                          ) : (
+                            formatValue(variable.value)
+                          ))}
                       </span>
                     </td>
                   </tr>
@@ -129,6 +155,34 @@ export function VariableTracker({ variables, previousVariables = [] }: VariableT
   );
 }
 
+// isGrid reports whether value is a matrix, such as a [3][3]string board or
+// a [][]int grid, which reads best as a table
+function isGrid(value: unknown): value is unknown[][] {
+  return (
+    Array.isArray(value) &&
+    value.length > 0 &&
+    value.every((row) => Array.isArray(row) && row.every((cell) => !Array.isArray(cell)))
+  );
+}
+
+function GridValue({ rows }: { rows: unknown[][] }) {
+  return (
+    <table className="text-xs my-0.5">
+      <tbody>
+        {rows.map((row, i) => (
+          <tr key={i}>
+            {row.map((cell, j) => (
+              <td key={j} className="border border-base-300 px-1.5 py-0 text-center">
+                {formatValue(cell)}
+              </td>
+            ))}
+          </tr>
+        ))}
+      </tbody>
+    </table>
+  );
+}
+
 function formatValue(value: unknown): string {
   if (value === null || value === undefined) {
     return 'nil';
@@ -1,11 +1,47 @@
-import { TraceRequest, TraceResponse } from '@/types/trace';
+import { ApiError, ShareRequest, ShareResponse, TraceRequest, TraceResponse } from '@/types/trace';
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
+const API_V1 = `${API_BASE_URL}/api/v1`;
+
+// TraceError carries the server's error object, with the position of the
+// problem in the program when there is one
+export class TraceError extends Error {
+  readonly detail?: ApiError;
+  readonly status: number;
+
+  constructor(status: number, detail?: ApiError) {
+    super(detail?.message || `HTTP error: ${status}`);
+    this.name = 'TraceError';
+    this.status = status;
+    this.detail = detail;
+  }
+}
+
+async function failure(response: Response): Promise<TraceError> {
+  const errorData = await response.json().catch(() => ({}));
+  return new TraceError(response.status, errorData.error);
+}
 
 export async function traceCode(code: string): Promise<TraceResponse> {
   const request: TraceRequest = { code };
 
-  const response = await fetch(`${API_BASE_URL}/api/trace`, {
+  const response = await fetch(`${API_V1}/trace`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    throw await failure(response);
+  }
+
+  return response.json();
+}
+
+export async function shareCode(request: ShareRequest): Promise<ShareResponse> {
+  const response = await fetch(`${API_V1}/share`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
@@ -14,8 +50,17 @@ export async function traceCode(code: string): Promise<TraceResponse> {
   });
 
   if (!response.ok) {
-    const errorData = await response.json().catch(() => ({}));
-    throw new Error(errorData.error || `HTTP error: ${response.status}`);
+    throw await failure(response);
+  }
+
+  return response.json();
+}
+
+export async function loadSharedTrace(id: string): Promise<TraceResponse> {
+  const response = await fetch(`${API_V1}/share/${encodeURIComponent(id)}`);
+
+  if (!response.ok) {
+    throw await failure(response);
   }
 
   return response.json();
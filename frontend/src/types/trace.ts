@@ -3,7 +3,11 @@ export interface Variable {
   name: string;
   type: string;
   value: unknown;
+  display?: string; // bytes and runes with their characters, e.g. 97 'a'
   scope: string;
+  len?: number; // slices only
+  cap?: number;
+  backing?: string; // e.g. #1, the same for slices and arrays sharing memory
 }
 
 // Loop iteration tracking
@@ -25,6 +29,8 @@ export interface TraceStep {
   loopIteration?: LoopIteration;
   callStack?: string[];
   functionName?: string;
+  conditionResult?: boolean;
+  goroutine?: number;
 }
 
 export type StatementType =
@@ -40,11 +46,14 @@ export type StatementType =
   | 'return'
   | 'break'
   | 'continue'
+  | 'goto'
+  | 'fallthrough'
   | 'func_call'
   | 'func_enter'
   | 'func_return'
   | 'switch_tag'
-  | 'case_match';
+  | 'case_match'
+  | 'panic';
 
 // AST node for visualization
 export interface ASTNode {
@@ -55,6 +64,8 @@ export interface ASTNode {
   endLine: number;
   children?: ASTNode[];
   parentId?: string;
+  goLabel?: string;
+  target?: string;
 }
 
 // Complete trace response from backend
@@ -73,4 +84,36 @@ export interface TraceResponse {
 // Request to trace endpoint
 export interface TraceRequest {
   code: string;
+  mode?: 'interpreter' | 'native';
+}
+
+// Request to save a program under a short ID
+export interface ShareRequest extends TraceRequest {
+  ttl?: string; // Go duration such as "720h"; server default when omitted
+}
+
+// Response from the share endpoint
+export interface ShareResponse {
+  id: string;
+  url: string;
+  expiresAt?: string;
+}
+
+// Error object returned by every failed /api/v1 request
+export interface ApiError {
+  code: string;
+  message: string;
+  line?: number;
+  column?: number;
+  phase?: 'parse' | 'typecheck' | 'execute' | 'limit';
+  diagnostics?: {
+    message: string;
+    line?: number;
+    column?: number;
+  }[];
+}
+
+export interface ErrorResponse {
+  success: false;
+  error: ApiError;
 }